@@ -0,0 +1,110 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// Raises an alarm when sampled values stay past a threshold for a minimum
+// duration, and clears it once they have stayed back inside the threshold,
+// less the hysteresis, for the same duration. Changes are published to the
+// bus as EventAlarm events.
+type Alarm struct {
+	// Identifies the alarm in events
+	Label     string
+	Threshold float64
+	// How far back past the threshold values must go to clear the alarm
+	Hysteresis float64
+	// Alarm when values are below the threshold, rather than above
+	Below bool
+	// How long a condition must hold before the alarm changes
+	Duration time.Duration
+	// Where events are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	active   bool
+	changing bool
+	since    time.Time
+	sequence uint64
+}
+
+// Whether the alarm is currently raised
+func (a *Alarm) Active() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.active
+}
+
+func (a *Alarm) exceeded(v float64) bool {
+	if a.Below {
+		return v < a.Threshold
+	}
+	return v > a.Threshold
+}
+
+func (a *Alarm) recovered(v float64) bool {
+	if a.Below {
+		return v >= a.Threshold+a.Hysteresis
+	}
+	return v <= a.Threshold-a.Hysteresis
+}
+
+// Feed the alarm a sample. Samples must be in time order.
+func (a *Alarm) Update(s Sample) {
+	e, changed := a.update(s)
+	if !changed {
+		return
+	}
+
+	bus := a.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	bus.Publish(e)
+}
+
+// Returns the event to publish if the alarm changed.
+func (a *Alarm) update(s Sample) (Event, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changing := (!a.active && a.exceeded(s.Value)) || (a.active && a.recovered(s.Value))
+	if !changing {
+		a.changing = false
+		return Event{}, false
+	}
+
+	if !a.changing {
+		a.changing = true
+		a.since = s.Time
+	}
+	if s.Time.Sub(a.since) < a.Duration {
+		return Event{}, false
+	}
+
+	a.active = !a.active
+	a.changing = false
+	a.sequence++
+
+	value := 0
+	if a.active {
+		value = 1
+	}
+	return Event{
+		Kind:     EventAlarm,
+		Label:    a.Label,
+		Value:    value,
+		Time:     s.Time,
+		Sequence: a.sequence,
+		Reading:  s.Value,
+	}, true
+}
+
+// Feeds the alarm every sample from the stream, until it is closed.
+func (a *Alarm) Monitor(samples <-chan Sample) {
+	for s := range samples {
+		a.Update(s)
+	}
+}
@@ -0,0 +1,77 @@
+package gpio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A table of names for channels, e.g. "pump" -> 17. Loading the table from a
+// file at runtime lets the same binary run on hardware revisions with
+// different wiring.
+type Aliases struct {
+	mu       sync.RWMutex
+	channels map[string]uint8
+}
+
+func NewAliases() *Aliases {
+	return &Aliases{channels: map[string]uint8{}}
+}
+
+// Points name at a channel, replacing any previous mapping.
+func (a *Aliases) Set(name string, channel uint8) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.channels[name] = channel
+}
+
+// Returns the channel name points at. Plain channel numbers are accepted too,
+// so config can mix names and numbers.
+func (a *Aliases) Lookup(name string) (uint8, error) {
+	a.mu.RLock()
+	channel, ok := a.channels[name]
+	a.mu.RUnlock()
+	if ok {
+		return channel, nil
+	}
+
+	if n, err := strconv.ParseUint(name, 10, 8); err == nil {
+		return uint8(n), nil
+	}
+	return 0, fmt.Errorf("gpio: no alias %q", name)
+}
+
+// Replaces the table with "name = channel" lines read from r. Blank lines and
+// lines starting with # are ignored.
+func (a *Aliases) Load(r io.Reader) error {
+	channels := map[string]uint8{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("gpio: alias line %d: expected name = channel", line)
+		}
+		channel, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 8)
+		if err != nil {
+			return fmt.Errorf("gpio: alias line %d: %v", line, err)
+		}
+		channels[strings.TrimSpace(parts[0])] = uint8(channel)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.channels = channels
+	a.mu.Unlock()
+	return nil
+}
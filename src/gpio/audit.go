@@ -0,0 +1,167 @@
+package gpio
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An entry in the audit log.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// The part of the application making the change
+	Who   string `json:"who"`
+	Label string `json:"label"`
+	// e.g. "set", "pwm", "close", or an application-defined config change
+	Action string `json:"action"`
+	// Empty when not known, e.g. before the first write
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Set if the change failed
+	Err string `json:"err,omitempty"`
+}
+
+// Somewhere to keep audit records.
+type AuditSink interface {
+	Audit(r AuditRecord) error
+}
+
+// Adapts a function to an AuditSink.
+type AuditSinkFunc func(r AuditRecord) error
+
+func (f AuditSinkFunc) Audit(r AuditRecord) error {
+	return f(r)
+}
+
+// Writes records to w as lines of JSON. Open files with os.O_APPEND to keep
+// the log append-only.
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	return &jsonAuditSink{encoder: json.NewEncoder(w)}
+}
+
+type jsonAuditSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func (s *jsonAuditSink) Audit(r AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.encoder.Encode(r)
+}
+
+// Records changes made by one part of an application. Wrap the pins that part
+// uses, and every change made through them is logged, failures included.
+type Auditor struct {
+	Sink AuditSink
+	Who  string
+}
+
+// Records an application-defined change, such as a configuration update.
+func (a *Auditor) Record(label, action, from, to string, err error) error {
+	r := AuditRecord{
+		Time:   time.Now(),
+		Who:    a.Who,
+		Label:  label,
+		Action: action,
+		Old:    from,
+		New:    to,
+	}
+	if err != nil {
+		r.Err = err.Error()
+	}
+	return a.Sink.Audit(r)
+}
+
+// Logs every change made through the returned pin.
+func (a *Auditor) Output(pin OutputPin, label string) OutputPin {
+	return &auditedOutputPin{auditor: a, label: label, pin: pin}
+}
+
+// Logs every change made through the returned pin.
+func (a *Auditor) PWM(pin PWMPin, label string) PWMPin {
+	return &auditedPWMPin{auditor: a, label: label, pin: pin}
+}
+
+// Records a change made by fn, returning fn's error, or the sink's if the
+// change couldn't be logged.
+func (a *Auditor) change(label, action string, current *string, to string, fn func() error) error {
+	err := fn()
+	auditErr := a.Record(label, action, *current, to, err)
+	if err != nil {
+		return err
+	}
+	*current = to
+	return auditErr
+}
+
+type auditedOutputPin struct {
+	mu      sync.Mutex
+	auditor *Auditor
+	label   string
+	pin     OutputPin
+	value   string
+}
+
+func (p *auditedOutputPin) SetHigh() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.auditor.change(p.label, "set", &p.value, GPIO_ON, p.pin.SetHigh)
+}
+
+func (p *auditedOutputPin) SetLow() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.auditor.change(p.label, "set", &p.value, GPIO_OFF, p.pin.SetLow)
+}
+
+func (p *auditedOutputPin) Toggle() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Not knowing what the pin was, the log can't say what it is now
+	to := "toggled"
+	switch p.value {
+	case GPIO_ON:
+		to = GPIO_OFF
+	case GPIO_OFF:
+		to = GPIO_ON
+	}
+	return p.auditor.change(p.label, "toggle", &p.value, to, p.pin.Toggle)
+}
+
+func (p *auditedOutputPin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.auditor.change(p.label, "close", &p.value, "", p.pin.Close)
+}
+
+type auditedPWMPin struct {
+	mu      sync.Mutex
+	auditor *Auditor
+	label   string
+	pin     PWMPin
+	value   string
+}
+
+func (p *auditedPWMPin) SetPWM(value int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.auditor.change(p.label, "pwm", &p.value, strconv.Itoa(value), func() error {
+		return p.pin.SetPWM(value)
+	})
+}
+
+func (p *auditedPWMPin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.auditor.change(p.label, "close", &p.value, "", p.pin.Close)
+}
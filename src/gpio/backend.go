@@ -0,0 +1,223 @@
+package gpio
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var ErrNotSupported = errors.New("gpio: not supported by this backend")
+
+// How pins reach the hardware. All hardware access by pins goes through a
+// backend, so applications can switch between Sysfs, Chardev, MMap or their
+// own implementations (a mock for tests, a remote Pi, ...) without changes.
+//
+// Channels are exported before use, and unexported when finished with.
+// Backends must be safe for concurrent use on different channels.
+type Backend interface {
+	Export(channel uint8) error
+	Unexport(channel uint8) error
+	SetDirection(channel uint8, direction Direction) error
+	Read(channel uint8) (int, error)
+	Write(channel uint8, value int) error
+	// Reports transitions on the edge as they happen. Calling again replaces
+	// the previous watch, and passing EdgeNone stops watching; either closes
+	// the previous channel. Backends which can't detect edges return
+	// ErrNotSupported.
+	WatchEdge(channel uint8, edge Edge) (<-chan Event, error)
+}
+
+// The backend pins use unless WithBackend is given.
+var DefaultBackend Backend = Sysfs
+
+// Internal resistor biasing an input
+type Pull int
+
+const (
+	PullNone Pull = iota
+	PullUp
+	PullDown
+)
+
+// Backends which can control the internal pull resistors.
+type PullBackend interface {
+	SetPull(channel uint8, pull Pull) error
+}
+
+// How an output drives the line
+type Drive int
+
+const (
+	// Drives both high and low. The default.
+	DrivePushPull Drive = iota
+	// Only drives low, letting the line be pulled high otherwise, for shared
+	// buses like I2C and 1-Wire.
+	DriveOpenDrain
+	// Only drives high
+	DriveOpenSource
+)
+
+// Backends which can set an output's drive in hardware. For other backends it
+// is emulated by switching the line between output and input.
+type DriveBackend interface {
+	SetDrive(channel uint8, drive Drive) error
+}
+
+// Backends which can take over channels left exported by another process,
+// such as an early-boot script, without resetting them.
+type AdoptBackend interface {
+	// Exports the channel if it isn't already, and returns its current
+	// direction.
+	Adopt(channel uint8) (Direction, error)
+}
+
+// Backends which can let go of a channel without unexporting it, leaving it
+// configured as it is.
+type ReleaseBackend interface {
+	Release(channel uint8) error
+}
+
+// Backends which can switch a channel to output already driving a value,
+// rather than driving it low until the first write. Without this, an output
+// opened WithInitialValue(1), such as an active-low relay's, glitches.
+type OutputBackend interface {
+	SetOutput(channel uint8, value int) error
+}
+
+// Configures a pin when it is opened.
+type Option func(*pin)
+
+// Treat the pin as active-low, for relay boards and other hardware which is
+// on when the line is low. SetHigh, IsHigh and edge events then work in
+// logical terms: SetHigh drives the line low, and a falling line is reported
+// as a rising edge.
+func WithActiveLow(activeLow bool) Option {
+	return func(p *pin) {
+		p.activeLow = activeLow
+	}
+}
+
+// Set how outputs drive the line. See Drive.
+func WithDrive(drive Drive) Option {
+	return func(p *pin) {
+		p.drive = drive
+	}
+}
+
+// Filter out contact bounce: readings must hold for d, and edge events are
+// only reported once the input has settled for d, at its settled level.
+func WithDebounce(d time.Duration) Option {
+	return func(p *pin) {
+		p.debounce = d
+	}
+}
+
+// Enable the internal pull-up or pull-down resistor when the pin is opened.
+// Opening fails with ErrNotSupported if the backend can't.
+func WithPull(pull Pull) Option {
+	return func(p *pin) {
+		p.pull = pull
+		p.hasPull = true
+		p.pullOptional = false
+	}
+}
+
+// A preset's pull, which WithPull overrides, and which is left alone on
+// backends without pull resistors.
+func withDefaultPull(pull Pull) Option {
+	return func(p *pin) {
+		p.pull = pull
+		p.hasPull = true
+		p.pullOptional = true
+	}
+}
+
+// Watch the edge from when the pin is opened, publishing events to
+// DefaultBus. Call Watch(EdgeNone) on the pin to stop, e.g. to watch it on a
+// channel instead.
+func WithEdge(edge Edge) Option {
+	return func(p *pin) {
+		p.edge = edge
+	}
+}
+
+// Set outputs to value as soon as they are opened.
+func WithInitialValue(value int) Option {
+	return func(p *pin) {
+		if value != 0 {
+			value = 1
+		}
+		p.initialValue = value
+		p.hasInitialValue = true
+	}
+}
+
+// Take over the channel as it is if already exported, instead of unexporting
+// and re-exporting it, so an output set up by an early-boot script doesn't
+// glitch. The direction is only changed if it differs, and WithInitialValue
+// only applies if it does. Opening fails with ErrNotSupported if the backend
+// can't adopt channels.
+func WithAdopt() Option {
+	return func(p *pin) {
+		p.adopt = true
+	}
+}
+
+// Run software PWM at hz rather than the default 50Hz, e.g. 1kHz for LEDs so
+// they don't visibly flicker. Opening fails if hz isn't a usable frequency.
+func WithPWMFrequency(hz float64) Option {
+	return func(p *pin) {
+		period, err := pwmPeriodFor(hz, minSoftwarePWMPeriod)
+		if err != nil {
+			p.optionErr = err
+			return
+		}
+		p.pwmPeriod = int64(period)
+	}
+}
+
+// Correct PWM duty cycles for the eye with a gamma curve, raising each to
+// exponent, so that equal steps look like equal steps in LED brightness.
+// 2.2-2.8 suits most LEDs. Fades are corrected too.
+func WithGamma(exponent float64) Option {
+	return func(p *pin) {
+		p.gamma = func(duty float64) float64 {
+			return math.Pow(duty, exponent)
+		}
+	}
+}
+
+// Correct PWM duty cycles through a lookup table instead of an exponent, for
+// LEDs measured by hand. Entries are output fractions from 0.0-1.0 for
+// duty cycles evenly spaced from 0 to 100%, interpolated between.
+func WithGammaTable(table []float64) Option {
+	table = append([]float64(nil), table...)
+	return func(p *pin) {
+		if len(table) < 2 {
+			return
+		}
+		p.gamma = func(duty float64) float64 {
+			x := duty * float64(len(table)-1)
+			i := int(x)
+			if i >= len(table)-1 {
+				return table[len(table)-1]
+			}
+			return table[i] + (table[i+1]-table[i])*(x-float64(i))
+		}
+	}
+}
+
+// Run software PWM from s's loop, shared with the other pins on it, rather
+// than a goroutine of the pin's own. The pin runs at s's frequency.
+func WithPWMScheduler(s *PWMScheduler) Option {
+	return func(p *pin) {
+		p.scheduler = s
+	}
+}
+
+// Use the given backend for the pin, instead of DefaultBackend.
+func WithBackend(b Backend) Option {
+	return func(p *pin) {
+		p.backend = b
+	}
+}
@@ -0,0 +1,164 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Returned by Bench when the input sees no edges from the output, as when
+// they aren't wired together.
+var ErrNoLoopback = errors.New("gpio: no loopback: the input saw no edge from the output")
+
+// How long Bench waits for each edge
+const benchEdgeTimeout = time.Second
+
+// Measurements of a backend on the current hardware, for choosing between
+// backends. Durations are in nanoseconds when encoded as JSON.
+type BenchResult struct {
+	// How many writes per second the backend manages
+	ToggleRate float64 `json:"toggle_rate_hz"`
+	// Average time for one read
+	ReadLatency time.Duration `json:"read_latency_ns"`
+	// Average time from a write to its edge event arriving. Zero when not
+	// measured.
+	EdgeLatency time.Duration `json:"edge_latency_ns,omitempty"`
+	// Standard deviation of the software PWM period. Zero when not measured.
+	PWMJitter time.Duration `json:"pwm_jitter_ns,omitempty"`
+}
+
+// Benchmarks a backend with n iterations of each measurement.
+//
+// Output is toggled and read. If input is a different channel, wired to
+// output, edge latency and PWM jitter are measured through it too, where the
+// backend supports edge events.
+func Bench(b Backend, output, input uint8, n int) (BenchResult, error) {
+	var result BenchResult
+	if n <= 0 {
+		return result, fmt.Errorf("gpio: invalid bench iterations %d", n)
+	}
+
+	if err := benchOutput(&result, b, output, n); err != nil {
+		return result, err
+	}
+	if input == output {
+		return result, nil
+	}
+
+	err := benchEdges(&result, b, output, input, n)
+	if errors.Is(err, ErrNotSupported) {
+		err = nil
+	}
+	return result, err
+}
+
+func benchOutput(result *BenchResult, b Backend, output uint8, n int) error {
+	out, err := NewOutputPin(output, WithBackend(b))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if err := out.SetHigh(); err != nil {
+			return err
+		}
+		if err := out.SetLow(); err != nil {
+			return err
+		}
+	}
+	result.ToggleRate = float64(2*n) / time.Since(start).Seconds()
+
+	start = time.Now()
+	for i := 0; i < n; i++ {
+		if _, err := b.Read(output); err != nil {
+			return err
+		}
+	}
+	result.ReadLatency = time.Since(start) / time.Duration(n)
+	return nil
+}
+
+func benchEdges(result *BenchResult, b Backend, output, input uint8, n int) error {
+	in, err := NewInputPin(input, WithBackend(b))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	events, err := in.Watch(EdgeRising)
+	if err != nil {
+		return err
+	}
+
+	// Opened as a PWM pin for the jitter measurement, but written directly
+	// first to time single edges.
+	out, err := NewPWMPin(output, WithBackend(b))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	writer := out.(OutputPin)
+
+	var total time.Duration
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		if err := writer.SetHigh(); err != nil {
+			return err
+		}
+		e, err := benchEdge(events)
+		if err != nil {
+			return err
+		}
+		total += e.Time.Sub(start)
+		if err := writer.SetLow(); err != nil {
+			return err
+		}
+	}
+	result.EdgeLatency = total / time.Duration(n)
+
+	// Time the period between rising edges of software PWM
+	if err := out.SetPWM(50); err != nil {
+		return err
+	}
+
+	periods := make([]float64, n)
+	e, err := benchEdge(events)
+	if err != nil {
+		return err
+	}
+	last := e.Time
+	for i := range periods {
+		e, err := benchEdge(events)
+		if err != nil {
+			return err
+		}
+		periods[i] = float64(e.Time.Sub(last))
+		last = e.Time
+	}
+
+	var mean, variance float64
+	for _, p := range periods {
+		mean += p / float64(n)
+	}
+	for _, p := range periods {
+		variance += (p - mean) * (p - mean) / float64(n)
+	}
+	result.PWMJitter = time.Duration(math.Sqrt(variance))
+	return nil
+}
+
+// Waits for the next edge, giving up after benchEdgeTimeout.
+func benchEdge(events <-chan Event) (Event, error) {
+	select {
+	case e, ok := <-events:
+		if !ok {
+			return Event{}, ErrNoLoopback
+		}
+		return e, nil
+	case <-time.After(benchEdgeTimeout):
+		return Event{}, ErrNoLoopback
+	}
+}
@@ -0,0 +1,69 @@
+package gpio
+
+import (
+	"sync"
+)
+
+// Drives several pins in lockstep as one logical output, e.g. pins paralleled
+// through resistors for more current. The pins are switched back to back,
+// with nothing else scheduled in between, so their transitions land close
+// together. An error leaves any pins already switched as they are.
+//
+// Closing the bond closes all the pins.
+func NewBondedOutputPin(pins ...OutputPin) OutputPin {
+	return &bondedOutputPin{pins: pins}
+}
+
+type bondedOutputPin struct {
+	mu   sync.Mutex
+	pins []OutputPin
+}
+
+func (b *bondedOutputPin) SetHigh() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, p := range b.pins {
+		if err := p.SetHigh(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *bondedOutputPin) SetLow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, p := range b.pins {
+		if err := p.SetLow(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *bondedOutputPin) Toggle() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, p := range b.pins {
+		if err := p.Toggle(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *bondedOutputPin) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var err error
+	for _, p := range b.pins {
+		if err2 := p.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
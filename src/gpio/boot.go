@@ -0,0 +1,63 @@
+package gpio
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The state a pin should have from power-on, before any daemon starts.
+type BootState struct {
+	Channel   uint8
+	Direction Direction
+	// The level outputs drive
+	Value int
+	// The resistor on inputs
+	Pull Pull
+}
+
+// The settings in the form config.txt and pinctrl take them, e.g. "op,dh".
+func (b BootState) settings() []string {
+	if b.Direction == DirectionOut {
+		level := "dl"
+		if b.Value != 0 {
+			level = "dh"
+		}
+		return []string{"op", level}
+	}
+
+	pull := "pn"
+	switch b.Pull {
+	case PullUp:
+		pull = "pu"
+	case PullDown:
+		pull = "pd"
+	}
+	return []string{"ip", pull}
+}
+
+// Writes gpio= lines for /boot/config.txt setting up the pins, which the
+// firmware applies at power-on.
+func WriteBootConfig(w io.Writer, pins []BootState) error {
+	for _, b := range pins {
+		if _, err := fmt.Fprintf(w, "gpio=%d=%s\n", b.Channel, strings.Join(b.settings(), ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes a systemd one-shot unit setting up the pins with pinctrl early in
+// boot, for systems where config.txt can't be changed.
+func WriteSystemdUnit(w io.Writer, pins []BootState) error {
+	if _, err := io.WriteString(w, "[Unit]\nDescription=Set up GPIO pins\nDefaultDependencies=no\nAfter=local-fs.target\n\n[Service]\nType=oneshot\n"); err != nil {
+		return err
+	}
+	for _, b := range pins {
+		if _, err := fmt.Fprintf(w, "ExecStart=/usr/bin/pinctrl set %d %s\n", b.Channel, strings.Join(b.settings(), " ")); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n[Install]\nWantedBy=sysinit.target\n")
+	return err
+}
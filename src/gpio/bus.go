@@ -0,0 +1,288 @@
+package gpio
+
+import (
+	"path"
+	"sync"
+	"sync/atomic"
+)
+
+// Selects which events a subscriber receives. The zero Filter matches
+// everything.
+type Filter struct {
+	// Only events of these kinds. Empty means any kind.
+	Kinds []EventKind
+	// Only events from these channels. Empty means any channel.
+	Channels []uint8
+	// Only events with this edge. Empty or EdgeBoth means any edge.
+	Edge Edge
+	// Only events whose label matches this glob, as in path.Match
+	Label string
+}
+
+func (f Filter) Match(e Event) bool {
+	if len(f.Kinds) > 0 {
+		found := false
+		for _, k := range f.Kinds {
+			if k == e.Kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Channels) > 0 {
+		found := false
+		for _, c := range f.Channels {
+			if c == e.Channel {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.Edge != "" && f.Edge != EdgeBoth && f.Edge != e.Edge {
+		return false
+	}
+
+	if f.Label != "" {
+		if ok, _ := path.Match(f.Label, e.Label); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// A publish/subscribe hub for events. Event sources publish into a bus, and
+// every subscriber whose filter matches gets a copy, so sources and sinks
+// don't need to know about each other.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]bool
+}
+
+// Components in this package publish their events here.
+var DefaultBus = NewBus()
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[*Subscription]bool{}}
+}
+
+// What to do when a subscriber isn't keeping up and its buffer is full.
+type Policy int
+
+const (
+	// Wait for the subscriber, holding up the publisher
+	PolicyBlock Policy = iota
+	// Discard the oldest buffered event to make room
+	PolicyDropOldest
+	// Discard the event being published
+	PolicyDropNewest
+	// Keep only the latest pending event for each source: the same kind,
+	// channel and label
+	PolicyCoalesce
+)
+
+// A subscriber's view of a bus.
+type Subscription struct {
+	// First, to keep it 64-bit aligned for atomic access on ARM
+	dropped uint64
+
+	filter Filter
+	policy Policy
+	bus    *Bus
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+	// Held by publishers while delivering, so Close can't close events
+	// under them
+	sendMu sync.RWMutex
+	closed bool
+
+	// Pending events for PolicyCoalesce, in arrival order by source
+	mu      sync.Mutex
+	pending map[coalesceKey]Event
+	order   []coalesceKey
+	wake    chan struct{}
+	pumped  chan struct{}
+}
+
+// Number of events buffered for each subscriber
+const subscriptionBuffer = 16
+
+// Start receiving events matching the filter. The publisher blocks while the
+// subscriber's buffer is full.
+func (b *Bus) Subscribe(f Filter) *Subscription {
+	return b.SubscribeWithPolicy(f, PolicyBlock)
+}
+
+// Start receiving events matching the filter, choosing how to handle falling
+// behind. Use a dropping or coalescing policy for slow sinks, so they can't
+// stall the publisher and every other subscriber.
+func (b *Bus) SubscribeWithPolicy(f Filter, p Policy) *Subscription {
+	s := &Subscription{
+		filter: f,
+		policy: p,
+		bus:    b,
+		events: make(chan Event, subscriptionBuffer),
+		done:   make(chan struct{}),
+	}
+
+	if p == PolicyCoalesce {
+		s.pending = map[coalesceKey]Event{}
+		s.wake = make(chan struct{}, 1)
+		s.pumped = make(chan struct{})
+		go s.pump()
+	}
+
+	b.mu.Lock()
+	b.subscribers[s] = true
+	b.mu.Unlock()
+
+	return s
+}
+
+// Deliver an event to all matching subscribers. The bus isn't locked while
+// delivering, so subscribers may publish, subscribe and close meanwhile.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	var matched []*Subscription
+	for s := range b.subscribers {
+		if s.filter.Match(e) {
+			matched = append(matched, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range matched {
+		s.deliver(e)
+	}
+}
+
+func (s *Subscription) deliver(e Event) {
+	s.sendMu.RLock()
+	defer s.sendMu.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	switch s.policy {
+	case PolicyDropNewest:
+		select {
+		case s.events <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case PolicyDropOldest:
+		for {
+			select {
+			case s.events <- e:
+				return
+			case <-s.done:
+				return
+			default:
+			}
+			select {
+			case <-s.events:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	case PolicyCoalesce:
+		key := coalesceKey{e.Kind, e.Channel, e.Label}
+		s.mu.Lock()
+		if _, ok := s.pending[key]; ok {
+			atomic.AddUint64(&s.dropped, 1)
+		} else {
+			s.order = append(s.order, key)
+		}
+		s.pending[key] = e
+		s.mu.Unlock()
+
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	default:
+		select {
+		case s.events <- e:
+		case <-s.done:
+		}
+	}
+}
+
+// Events with the same key replace each other under PolicyCoalesce. Many
+// sources, such as alarms and counters, don't have a channel, so the kind
+// and label tell them apart.
+type coalesceKey struct {
+	kind    EventKind
+	channel uint8
+	label   string
+}
+
+// Feeds coalesced events to the subscriber as it is ready for them.
+func (s *Subscription) pump() {
+	defer close(s.pumped)
+	for {
+		s.mu.Lock()
+		if len(s.order) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		e := s.pending[s.order[0]]
+		delete(s.pending, s.order[0])
+		s.order = s.order[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.events <- e:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// The subscribed events. Closed when the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// How many events have been discarded or coalesced by the policy.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Stop receiving events.
+func (s *Subscription) Close() error {
+	s.once.Do(func() {
+		// Unblock any publisher waiting on us before taking the lock
+		close(s.done)
+
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+
+		// Wait for publishers still delivering, which done has unblocked
+		s.sendMu.Lock()
+		s.closed = true
+		s.sendMu.Unlock()
+
+		if s.pumped != nil {
+			<-s.pumped
+		}
+		close(s.events)
+	})
+	return nil
+}
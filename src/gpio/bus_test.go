@@ -0,0 +1,126 @@
+package gpio_test
+
+import (
+	"testing"
+	"time"
+
+	"gpio"
+)
+
+// Reads what is buffered in sub without blocking.
+func drain(sub *gpio.Subscription) []gpio.Event {
+	var events []gpio.Event
+	for {
+		select {
+		case e := <-sub.Events():
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestBusDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy gpio.Policy
+		// The first and last values left buffered of the 20 published
+		first, last int
+	}{
+		{policy: gpio.PolicyDropNewest, first: 0, last: 15},
+		{policy: gpio.PolicyDropOldest, first: 4, last: 19},
+	}
+
+	for _, tt := range tests {
+		bus := gpio.NewBus()
+		sub := bus.SubscribeWithPolicy(gpio.Filter{}, tt.policy)
+
+		const published = 20
+		for i := 0; i < published; i++ {
+			bus.Publish(gpio.Event{Kind: gpio.EventChange, Value: i})
+		}
+
+		events := drain(sub)
+		if len(events) == 0 {
+			t.Fatalf("policy %d: nothing delivered", tt.policy)
+		}
+		if got := events[0].Value; got != tt.first {
+			t.Errorf("policy %d: first value %d, want %d", tt.policy, got, tt.first)
+		}
+		if got := events[len(events)-1].Value; got != tt.last {
+			t.Errorf("policy %d: last value %d, want %d", tt.policy, got, tt.last)
+		}
+		for i := 1; i < len(events); i++ {
+			if events[i].Value != events[i-1].Value+1 {
+				t.Errorf("policy %d: %d followed by %d", tt.policy, events[i-1].Value, events[i].Value)
+			}
+		}
+		if got := sub.Dropped() + uint64(len(events)); got != published {
+			t.Errorf("policy %d: %d delivered and %d dropped, want %d in all", tt.policy, len(events), sub.Dropped(), published)
+		}
+		sub.Close()
+	}
+}
+
+func TestBusCoalesce(t *testing.T) {
+	// Events from different sources, by kind, channel and label, are never
+	// coalesced with each other.
+	sources := []gpio.Event{
+		{Kind: gpio.EventChange, Channel: 1},
+		{Kind: gpio.EventChange, Channel: 2},
+		{Kind: gpio.EventAlarm, Channel: 1},
+		{Kind: gpio.EventChange, Channel: 1, Label: "door"},
+	}
+
+	bus := gpio.NewBus()
+	sub := bus.SubscribeWithPolicy(gpio.Filter{}, gpio.PolicyCoalesce)
+	defer sub.Close()
+
+	// Many more than fit in the buffer, so most are coalesced
+	const rounds = 50
+	for i := 0; i < rounds; i++ {
+		for _, e := range sources {
+			e.Value = i
+			bus.Publish(e)
+		}
+	}
+
+	last := map[source]int{}
+	received := 0
+	deadline := time.After(time.Second)
+	for len(last) < len(sources) || !allEqual(last, rounds-1) {
+		select {
+		case e := <-sub.Events():
+			key := source{e.Kind, e.Channel, e.Label}
+			if prev, ok := last[key]; ok && e.Value <= prev {
+				t.Fatalf("%v: value %d after %d", key, e.Value, prev)
+			}
+			last[key] = e.Value
+			received++
+		case <-deadline:
+			t.Fatalf("timed out with latest values %v", last)
+		}
+	}
+
+	if got := uint64(received) + sub.Dropped(); got != rounds*uint64(len(sources)) {
+		t.Errorf("%d delivered and %d coalesced, want %d in all", received, sub.Dropped(), rounds*len(sources))
+	}
+	if sub.Dropped() == 0 {
+		t.Error("nothing coalesced")
+	}
+}
+
+// Where an event came from, as PolicyCoalesce sees it
+type source struct {
+	kind    gpio.EventKind
+	channel uint8
+	label   string
+}
+
+func allEqual(values map[source]int, want int) bool {
+	for _, v := range values {
+		if v != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// Wraps an input so that a reading is reused for up to ttl before the pin is
+// read again. Code polling IsHigh in a tight loop then doesn't hammer sysfs,
+// while a small ttl (e.g. 1ms) still picks up changes promptly.
+func NewCachedInputPin(pin InputPin, ttl time.Duration) InputPin {
+	return &cachedInputPin{InputPin: pin, ttl: ttl}
+}
+
+type cachedInputPin struct {
+	InputPin
+	ttl time.Duration
+
+	mu     sync.Mutex
+	value  int
+	err    error
+	readAt time.Time
+}
+
+func (c *cachedInputPin) GetValue() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readAt.IsZero() || time.Since(c.readAt) >= c.ttl {
+		c.value, c.err = c.InputPin.GetValue()
+		c.readAt = time.Now()
+	}
+	return c.value, c.err
+}
+
+func (c *cachedInputPin) IsHigh() (bool, error) {
+	val, err := c.GetValue()
+	return (val == 1), err
+}
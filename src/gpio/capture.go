@@ -0,0 +1,81 @@
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Takes a picture, or whatever else should happen, for an event.
+type CaptureFunc func(ctx context.Context, e Event) error
+
+// Captures on matching events, for doorbells and trail cameras triggered by a
+// button or PIR sensor.
+type CaptureHook struct {
+	Filter  Filter
+	Capture CaptureFunc
+	// Wait this long after the event before capturing, e.g. for a visitor to
+	// step into frame
+	Delay time.Duration
+	// Ignore events for this long after a capture finishes, so one visitor
+	// doesn't fill the card
+	Holdoff time.Duration
+	// Called when a capture fails
+	OnError func(e Event, err error)
+}
+
+// Captures for events from bus (or DefaultBus if nil) until ctx is
+// cancelled. Captures run one at a time; events arriving meanwhile are
+// dropped.
+func (h *CaptureHook) Run(ctx context.Context, bus *Bus) error {
+	if bus == nil {
+		bus = DefaultBus
+	}
+
+	sub := bus.SubscribeWithPolicy(h.Filter, PolicyDropNewest)
+	defer sub.Close()
+
+	var ready time.Time
+	for {
+		select {
+		case e := <-sub.Events():
+			if time.Now().Before(ready) {
+				continue
+			}
+			if err := h.capture(ctx, e); err != nil && h.OnError != nil {
+				h.OnError(e, err)
+			}
+			ready = time.Now().Add(h.Holdoff)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (h *CaptureHook) capture(ctx context.Context, e Event) error {
+	timer := time.NewTimer(h.Delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return h.Capture(ctx, e)
+}
+
+// Captures a still with libcamera-still into dir, named after the event's
+// time. Extra arguments, e.g. "--width", "1920", are passed through.
+func LibcameraStill(dir string, args ...string) CaptureFunc {
+	return func(ctx context.Context, e Event) error {
+		name := fmt.Sprintf("%s-%d.jpg", e.Time.Format("20060102-150405.000"), e.Channel)
+		cmd := exec.CommandContext(ctx, "libcamera-still",
+			append([]string{"-n", "-o", filepath.Join(dir, name)}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("gpio: libcamera-still: %v: %s", err, out)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,364 @@
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Structures and ioctls from the v2 GPIO character device ABI in
+// <linux/gpio.h>. Fields are laid out so the structs match the kernel's on
+// both 32 and 64-bit ARM.
+
+const (
+	gpioV2LinesMax       = 64
+	gpioV2LineNumAttrMax = 10
+	gpioMaxNameSize      = 32
+
+	gpioV2LineFlagActiveLow    = 1 << 1
+	gpioV2LineFlagInput        = 1 << 2
+	gpioV2LineFlagOutput       = 1 << 3
+	gpioV2LineFlagEdgeRising   = 1 << 4
+	gpioV2LineFlagEdgeFalling  = 1 << 5
+	gpioV2LineFlagOpenDrain    = 1 << 6
+	gpioV2LineFlagOpenSource   = 1 << 7
+	gpioV2LineFlagBiasPullUp   = 1 << 8
+	gpioV2LineFlagBiasPullDown = 1 << 9
+	gpioV2LineFlagBiasDisabled = 1 << 10
+
+	gpioV2LineAttrIDOutputValues = 2
+
+	gpioV2LineEventRisingEdge  = 1
+	gpioV2LineEventFallingEdge = 2
+)
+
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [gpioV2LineNumAttrMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [gpioV2LinesMax]uint32
+	consumer        [gpioMaxNameSize]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+type gpioV2LineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+// _IOWR(0xB4, nr, size)
+func gpioIOWR(nr, size uintptr) uintptr {
+	return 3<<30 | size<<16 | 0xB4<<8 | nr
+}
+
+var (
+	gpioV2GetLineIoctl       = gpioIOWR(0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineSetConfigIoctl = gpioIOWR(0x0D, unsafe.Sizeof(gpioV2LineConfig{}))
+	gpioV2LineGetValuesIoctl = gpioIOWR(0x0E, unsafe.Sizeof(gpioV2LineValues{}))
+	gpioV2LineSetValuesIoctl = gpioIOWR(0x0F, unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// The Linux GPIO character device on the Pi's main GPIO chip. Channels are
+// line offsets on the chip.
+var Chardev Backend = NewChardevBackend("/dev/gpiochip0")
+
+// Creates a backend for the GPIO character device at path, e.g.
+// "/dev/gpiochip0". This replaces the deprecated sysfs interface on modern
+// kernels. Lines are requested when exported and released when unexported,
+// so nothing is left behind if the process dies.
+func NewChardevBackend(path string) Backend {
+	return &chardevBackend{
+		path:  path,
+		lines: map[uint8]*chardevLine{},
+	}
+}
+
+type chardevBackend struct {
+	path  string
+	mu    sync.Mutex
+	lines map[uint8]*chardevLine
+}
+
+type chardevLine struct {
+	fd    int
+	flags uint64
+	watch *edgeWatch
+}
+
+func (c *chardevBackend) line(channel uint8) (*chardevLine, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	line, ok := c.lines[channel]
+	if !ok {
+		return nil, ErrNotExported
+	}
+	return line, nil
+}
+
+func (c *chardevBackend) Export(channel uint8) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.lines[channel]; ok {
+		return fmt.Errorf("gpio: channel %d is already exported", channel)
+	}
+
+	chip, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer chip.Close()
+
+	req := gpioV2LineRequest{numLines: 1}
+	req.offsets[0] = uint32(channel)
+	copy(req.consumer[:], "gpio")
+	req.config.flags = gpioV2LineFlagInput
+	if err := ioctl(int(chip.Fd()), gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return err
+	}
+
+	c.lines[channel] = &chardevLine{fd: int(req.fd), flags: req.config.flags}
+	return nil
+}
+
+func (c *chardevBackend) Unexport(channel uint8) error {
+	c.mu.Lock()
+	line, ok := c.lines[channel]
+	delete(c.lines, channel)
+
+	var w *edgeWatch
+	if ok {
+		w, line.watch = line.watch, nil
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if w != nil {
+		w.stop()
+	}
+	return syscall.Close(line.fd)
+}
+
+// An attribute setting the output value of a single line.
+func outputValue(value int) gpioV2LineConfigAttribute {
+	return gpioV2LineConfigAttribute{
+		attr: gpioV2LineAttribute{id: gpioV2LineAttrIDOutputValues, value: uint64(value & 1)},
+		mask: 1,
+	}
+}
+
+// Changes the flags of an exported line.
+func (c *chardevBackend) reconfigure(channel uint8, clear, set uint64, attrs ...gpioV2LineConfigAttribute) error {
+	line, err := c.line(channel)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	flags := line.flags&^clear | set
+	if len(attrs) == 0 && line.flags&flags&gpioV2LineFlagOutput != 0 {
+		// The kernel drives outputs low when their config has no value, so
+		// keep an output at its current one
+		values := gpioV2LineValues{mask: 1}
+		if err := ioctl(line.fd, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values)); err != nil {
+			return err
+		}
+		attrs = append(attrs, outputValue(int(values.bits)))
+	}
+
+	config := gpioV2LineConfig{flags: flags, numAttrs: uint32(len(attrs))}
+	copy(config.attrs[:], attrs)
+	if err := ioctl(line.fd, gpioV2LineSetConfigIoctl, unsafe.Pointer(&config)); err != nil {
+		return err
+	}
+	line.flags = config.flags
+	return nil
+}
+
+func (c *chardevBackend) SetDirection(channel uint8, direction Direction) error {
+	if direction == DirectionOut {
+		return c.reconfigure(channel, gpioV2LineFlagInput|gpioV2LineFlagEdgeRising|gpioV2LineFlagEdgeFalling, gpioV2LineFlagOutput)
+	}
+	return c.reconfigure(channel, gpioV2LineFlagOutput, gpioV2LineFlagInput)
+}
+
+// Switches to output with the value in the same line config, so the kernel
+// never drives it otherwise.
+func (c *chardevBackend) SetOutput(channel uint8, value int) error {
+	return c.reconfigure(channel, gpioV2LineFlagInput|gpioV2LineFlagEdgeRising|gpioV2LineFlagEdgeFalling, gpioV2LineFlagOutput, outputValue(value))
+}
+
+func (c *chardevBackend) SetDrive(channel uint8, drive Drive) error {
+	var flags uint64
+	switch drive {
+	case DriveOpenDrain:
+		flags = gpioV2LineFlagOpenDrain
+	case DriveOpenSource:
+		flags = gpioV2LineFlagOpenSource
+	}
+	return c.reconfigure(channel, gpioV2LineFlagOpenDrain|gpioV2LineFlagOpenSource, flags)
+}
+
+func (c *chardevBackend) SetPull(channel uint8, pull Pull) error {
+	var bias uint64
+	switch pull {
+	case PullUp:
+		bias = gpioV2LineFlagBiasPullUp
+	case PullDown:
+		bias = gpioV2LineFlagBiasPullDown
+	default:
+		bias = gpioV2LineFlagBiasDisabled
+	}
+	return c.reconfigure(channel, gpioV2LineFlagBiasPullUp|gpioV2LineFlagBiasPullDown|gpioV2LineFlagBiasDisabled, bias)
+}
+
+func (c *chardevBackend) Read(channel uint8) (int, error) {
+	line, err := c.line(channel)
+	if err != nil {
+		return 0, err
+	}
+
+	values := gpioV2LineValues{mask: 1}
+	if err := ioctl(line.fd, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values)); err != nil {
+		return 0, err
+	}
+	return int(values.bits & 1), nil
+}
+
+func (c *chardevBackend) Write(channel uint8, value int) error {
+	line, err := c.line(channel)
+	if err != nil {
+		return err
+	}
+
+	values := gpioV2LineValues{mask: 1}
+	if value != 0 {
+		values.bits = 1
+	}
+	return ioctl(line.fd, gpioV2LineSetValuesIoctl, unsafe.Pointer(&values))
+}
+
+// The current CLOCK_MONOTONIC time, which kernel event timestamps use.
+func monotonicNow() (time.Duration, error) {
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0, errno
+	}
+	return time.Duration(ts.Nano()), nil
+}
+
+// Enables edge detection on the line, and reads the kernel's timestamped
+// events from it.
+func (c *chardevBackend) WatchEdge(channel uint8, edge Edge) (<-chan Event, error) {
+	line, err := c.line(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	old := line.watch
+	line.watch = nil
+	c.mu.Unlock()
+	if old != nil {
+		old.stop()
+	}
+
+	var flags uint64
+	switch edge {
+	case EdgeRising:
+		flags = gpioV2LineFlagEdgeRising
+	case EdgeFalling:
+		flags = gpioV2LineFlagEdgeFalling
+	case EdgeBoth:
+		flags = gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	}
+	if err := c.reconfigure(channel, gpioV2LineFlagEdgeRising|gpioV2LineFlagEdgeFalling, flags); err != nil {
+		return nil, err
+	}
+	if edge == EdgeNone {
+		return nil, nil
+	}
+
+	chip := filepath.Base(c.path)
+	w, events, err := startEdgeWatch(line.fd, syscall.EPOLLIN, func() (Event, error) {
+		var raw gpioV2LineEvent
+		buf := (*[unsafe.Sizeof(raw)]byte)(unsafe.Pointer(&raw))[:]
+		if _, err := syscall.Read(line.fd, buf); err != nil {
+			return Event{}, err
+		}
+
+		// Convert the monotonic timestamp to a time.Time by its age, so the
+		// time keeps a monotonic reading for measuring intervals.
+		now := time.Now()
+		mono, err := monotonicNow()
+		if err != nil {
+			return Event{}, err
+		}
+
+		e := Event{
+			Kind:     EventChange,
+			Channel:  channel,
+			Chip:     chip,
+			Edge:     EdgeFalling,
+			Time:     now.Add(-(mono - time.Duration(raw.timestampNs))),
+			Sequence: uint64(raw.lineSeqno),
+		}
+		if raw.id == gpioV2LineEventRisingEdge {
+			e.Edge = EdgeRising
+			e.Value = 1
+		}
+		return e, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	line.watch = w
+	c.mu.Unlock()
+	return events, nil
+}
@@ -0,0 +1,134 @@
+package gpio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Detects inputs toggling abnormally fast, such as a float switch chattering
+// on a rippling surface or a failing contact. An EventChatter is published
+// when a channel starts chattering, and again once it is stable.
+type ChatterDetector struct {
+	// Shared by the streams passing through, so updated with atomics. First,
+	// to keep it 64-bit aligned for atomic access on ARM.
+	sequence uint64
+
+	// A channel is chattering when more than MaxEdges events arrive within
+	// Window.
+	MaxEdges int
+	Window   time.Duration
+	// How long a chattering channel must go without events to be stable
+	// again. Window when zero.
+	Settle time.Duration
+	// Drop events from chattering channels. Once a channel is stable, the
+	// last dropped event is passed on, so downstream sees the settled level.
+	Suppress bool
+	// Identifies the detector in events
+	Label string
+	// Where events are published. DefaultBus when nil.
+	Bus *Bus
+}
+
+type chatterState struct {
+	recent     []time.Time
+	chattering bool
+	last       time.Time
+	suppressed *Event
+}
+
+// Passes events through, watching their rate. The returned channel is closed
+// when events is.
+func (d *ChatterDetector) Events(events <-chan Event) <-chan Event {
+	settle := d.Settle
+	if settle <= 0 {
+		settle = d.Window
+	}
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+
+		// Check for settled channels a few times per settling time
+		tick := settle / 4
+		if tick <= 0 {
+			tick = time.Millisecond
+		}
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		channels := map[uint8]*chatterState{}
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				s, ok := channels[e.Channel]
+				if !ok {
+					s = &chatterState{}
+					channels[e.Channel] = s
+				}
+				if d.observe(s, e) {
+					out <- e
+				}
+
+			case now := <-ticker.C:
+				for channel, s := range channels {
+					if !s.chattering || now.Sub(s.last) < settle {
+						continue
+					}
+					s.chattering = false
+					s.recent = s.recent[:0]
+					d.publish(channel, 0, now)
+					if s.suppressed != nil {
+						out <- *s.suppressed
+						s.suppressed = nil
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Records e, returning whether to pass it on.
+func (d *ChatterDetector) observe(s *chatterState, e Event) bool {
+	s.last = e.Time
+
+	// Forget events which have left the window
+	keep := s.recent[:0]
+	for _, t := range s.recent {
+		if e.Time.Sub(t) < d.Window {
+			keep = append(keep, t)
+		}
+	}
+	s.recent = append(keep, e.Time)
+
+	if !s.chattering && len(s.recent) > d.MaxEdges {
+		s.chattering = true
+		d.publish(e.Channel, 1, e.Time)
+	}
+
+	if s.chattering && d.Suppress {
+		s.suppressed = &e
+		return false
+	}
+	return true
+}
+
+func (d *ChatterDetector) publish(channel uint8, value int, t time.Time) {
+	sequence := atomic.AddUint64(&d.sequence, 1)
+
+	bus := d.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	bus.Publish(Event{
+		Kind:     EventChatter,
+		Label:    d.Label,
+		Channel:  channel,
+		Value:    value,
+		Time:     t,
+		Sequence: sequence,
+	})
+}
@@ -0,0 +1,126 @@
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An external command to run when matching events happen.
+type CommandHook struct {
+	Filter Filter
+	// The program and its arguments. Details of the event are passed in the
+	// environment as GPIO_KIND, GPIO_LABEL, GPIO_CHANNEL, GPIO_EDGE,
+	// GPIO_VALUE, GPIO_TIME and GPIO_SEQUENCE.
+	Command []string
+	// Wait until events have stopped for this long, then run once for the
+	// last of them.
+	Debounce time.Duration
+	// Run at most once in this interval, ignoring events in between.
+	MinInterval time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	lastRun time.Time
+}
+
+// Runs commands on events from a bus, so scripts can be hooked up to pins
+// without writing Go.
+type CommandRunner struct {
+	Hooks []*CommandHook
+	// Where command output goes. Discarded when nil.
+	Output io.Writer
+	// Called when a command can't be run or exits unsuccessfully
+	OnError func(hook *CommandHook, err error)
+}
+
+// Runs hooks for events from bus (or DefaultBus if nil) until ctx is
+// cancelled. Commands run in the background, and events arriving faster than
+// they can be handled are dropped rather than holding up the bus.
+func (r *CommandRunner) Run(ctx context.Context, bus *Bus) error {
+	if bus == nil {
+		bus = DefaultBus
+	}
+
+	sub := bus.SubscribeWithPolicy(Filter{}, PolicyDropNewest)
+	defer sub.Close()
+
+	for {
+		select {
+		case e := <-sub.Events():
+			for _, hook := range r.Hooks {
+				if hook.Filter.Match(e) {
+					r.trigger(ctx, hook, e)
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *CommandRunner) trigger(ctx context.Context, hook *CommandHook, e Event) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+
+	if hook.Debounce > 0 {
+		if hook.timer != nil {
+			hook.timer.Stop()
+		}
+		hook.timer = time.AfterFunc(hook.Debounce, func() {
+			hook.mu.Lock()
+			defer hook.mu.Unlock()
+
+			r.start(ctx, hook, e)
+		})
+		return
+	}
+	r.start(ctx, hook, e)
+}
+
+// Starts the hook's command, unless it ran too recently. Called with hook.mu
+// held.
+func (r *CommandRunner) start(ctx context.Context, hook *CommandHook, e Event) {
+	if ctx.Err() != nil {
+		return
+	}
+	if !hook.lastRun.IsZero() && time.Since(hook.lastRun) < hook.MinInterval {
+		return
+	}
+	hook.lastRun = time.Now()
+
+	if len(hook.Command) == 0 {
+		r.fail(hook, fmt.Errorf("gpio: hook has no command"))
+		return
+	}
+
+	cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"GPIO_KIND="+string(e.Kind),
+		"GPIO_LABEL="+e.Label,
+		"GPIO_CHANNEL="+strconv.Itoa(int(e.Channel)),
+		"GPIO_EDGE="+string(e.Edge),
+		"GPIO_VALUE="+strconv.Itoa(e.Value),
+		"GPIO_TIME="+e.Time.Format(time.RFC3339Nano),
+		"GPIO_SEQUENCE="+strconv.FormatUint(e.Sequence, 10),
+	)
+	cmd.Stdout = r.Output
+	cmd.Stderr = r.Output
+
+	go func() {
+		if err := cmd.Run(); err != nil {
+			r.fail(hook, err)
+		}
+	}()
+}
+
+func (r *CommandRunner) fail(hook *CommandHook, err error) {
+	if r.OnError != nil {
+		r.OnError(hook, err)
+	}
+}
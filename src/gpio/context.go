@@ -0,0 +1,54 @@
+package gpio
+
+import (
+	"context"
+)
+
+// Opens an input which is closed when ctx is done, e.g. on server shutdown.
+func OpenInputPin(ctx context.Context, channel uint8, options ...Option) (InputPin, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p, err := NewInputPin(channel, options...)
+	if err != nil {
+		return nil, err
+	}
+	closeWhenDone(ctx, p.(*pin))
+	return p, nil
+}
+
+// Opens an output which is closed when ctx is done.
+func OpenOutputPin(ctx context.Context, channel uint8, options ...Option) (OutputPin, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p, err := NewOutputPin(channel, options...)
+	if err != nil {
+		return nil, err
+	}
+	closeWhenDone(ctx, p.(*pin))
+	return p, nil
+}
+
+// Opens a PWM output which is closed when ctx is done, stopping the PWM loop.
+func OpenPWMPin(ctx context.Context, channel uint8, options ...Option) (PWMPin, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p, err := NewPWMPin(channel, options...)
+	if err != nil {
+		return nil, err
+	}
+	closeWhenDone(ctx, p.(*pin))
+	return p, nil
+}
+
+func closeWhenDone(ctx context.Context, p *pin) {
+	// Held while assigning, as ctx may already be done and Close running
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopContext = context.AfterFunc(ctx, func() {
+		p.Close()
+	})
+}
@@ -0,0 +1,114 @@
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Counts people or objects passing through a doorway or along a conveyor with
+// two beams, telling the direction from which beam is broken first. Each
+// count is published as an EventCount labelled Label+".in" or Label+".out",
+// with the new total in Reading.
+type PeopleCounter struct {
+	Label string
+	// The beam broken first on the way in, and the one broken second
+	Outer, Inner uint8
+	// The edge when a beam is broken. EdgeFalling when empty.
+	Edge Edge
+	// The time allowed between the two beams
+	Within time.Duration
+	// Where counts are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	in, out  uint64
+	sequence uint64
+}
+
+// Counts passages seen in events from bus (or DefaultBus if nil) until ctx
+// is cancelled.
+func (c *PeopleCounter) Run(ctx context.Context, bus *Bus) error {
+	if bus == nil {
+		bus = DefaultBus
+	}
+	edge := c.Edge
+	if edge == "" {
+		edge = EdgeFalling
+	}
+
+	beams := bus.SubscribeWithPolicy(Filter{Kinds: []EventKind{EventChange}, Channels: []uint8{c.Outer, c.Inner}, Edge: edge}, PolicyDropOldest)
+	defer beams.Close()
+
+	// One passage at a time: the beam broken first, and when. The second
+	// beam completes it, and doesn't start another.
+	var (
+		started bool
+		first   uint8
+		since   time.Time
+	)
+	for {
+		select {
+		case e := <-beams.Events():
+			switch {
+			case !started || e.Time.Sub(since) > c.Within || e.Channel == first:
+				started, first, since = true, e.Channel, e.Time
+			default:
+				started = false
+				c.count(first == c.Outer, e.Time)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *PeopleCounter) count(in bool, t time.Time) {
+	c.mu.Lock()
+	label, total := c.Label+".out", &c.out
+	if in {
+		label, total = c.Label+".in", &c.in
+	}
+	*total++
+	c.sequence++
+	e := Event{Kind: EventCount, Label: label, Time: t, Sequence: c.sequence, Reading: float64(*total)}
+	c.mu.Unlock()
+
+	bus := c.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	bus.Publish(e)
+}
+
+// The totals counted in and out
+func (c *PeopleCounter) Totals() (in, out uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.in, c.out
+}
+
+// How many are inside: those in less those out, if counting started empty.
+func (c *PeopleCounter) Occupancy() int64 {
+	in, out := c.Totals()
+	return int64(in) - int64(out)
+}
+
+// Zeroes the totals, e.g. at midnight.
+func (c *PeopleCounter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.in, c.out = 0, 0
+}
+
+// Writes the totals in the Prometheus text exposition format, as a counter
+// named name with a direction label.
+func (c *PeopleCounter) WritePrometheus(w io.Writer, name string) error {
+	in, out := c.Totals()
+	_, err := fmt.Fprintf(w, "# TYPE %s counter\n%s{direction=\"in\"} %d\n%s{direction=\"out\"} %d\n", name, name, in, name, out)
+	return err
+}
@@ -0,0 +1,122 @@
+package gpio_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gpio"
+)
+
+func TestPeopleCounterDirection(t *testing.T) {
+	const outer, inner = 5, 6
+
+	type beam struct {
+		channel uint8
+		// When the beam is broken, after the start
+		at time.Duration
+	}
+	tests := []struct {
+		name    string
+		beams   []beam
+		in, out uint64
+	}{
+		{name: "in", beams: []beam{{outer, 0}, {inner, 100 * time.Millisecond}}, in: 1},
+		{name: "out", beams: []beam{{inner, 0}, {outer, 100 * time.Millisecond}}, out: 1},
+		{name: "too slow", beams: []beam{{outer, 0}, {inner, 2 * time.Second}}},
+		{name: "turned back", beams: []beam{{outer, 0}, {outer, 500 * time.Millisecond}}},
+		{name: "outer twice then in", beams: []beam{{outer, 0}, {outer, 300 * time.Millisecond}, {inner, 400 * time.Millisecond}}, in: 1},
+		{name: "in then out", beams: []beam{
+			{outer, 0}, {inner, 100 * time.Millisecond},
+			{inner, 3 * time.Second}, {outer, 3100 * time.Millisecond},
+		}, in: 1, out: 1},
+		{name: "three in", beams: []beam{
+			{outer, 0}, {inner, 100 * time.Millisecond},
+			{outer, 2 * time.Second}, {inner, 2100 * time.Millisecond},
+			{outer, 4 * time.Second}, {inner, 4100 * time.Millisecond},
+		}, in: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := gpio.NewBus()
+			counts := bus.Subscribe(gpio.Filter{Kinds: []gpio.EventKind{gpio.EventCount}})
+			defer counts.Close()
+
+			c := &gpio.PeopleCounter{Label: "door", Outer: outer, Inner: inner, Within: time.Second, Bus: bus}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go c.Run(ctx, bus)
+
+			// The event times are all made up, an hour apart between passages
+			// so each starts afresh
+			start := time.Now()
+			pass := func(first, second uint8) {
+				start = start.Add(time.Hour)
+				bus.Publish(gpio.Event{Kind: gpio.EventChange, Channel: first, Edge: gpio.EdgeFalling, Time: start})
+				bus.Publish(gpio.Event{Kind: gpio.EventChange, Channel: second, Edge: gpio.EdgeFalling, Time: start.Add(time.Millisecond)})
+			}
+
+			// Once a passage is counted, Run is listening
+			synced := false
+			for i := 0; i < 100 && !synced; i++ {
+				pass(outer, inner)
+				select {
+				case <-counts.Events():
+					synced = true
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			if !synced {
+				t.Fatal("counter never started")
+			}
+			// Until any warm-up passage still in flight is counted too
+			for quiet := false; !quiet; {
+				select {
+				case <-counts.Events():
+				case <-time.After(50 * time.Millisecond):
+					quiet = true
+				}
+			}
+			c.Reset()
+
+			start = start.Add(time.Hour)
+			for _, b := range tt.beams {
+				bus.Publish(gpio.Event{Kind: gpio.EventChange, Channel: b.channel, Edge: gpio.EdgeFalling, Time: start.Add(b.at)})
+			}
+			// A passage after the test's, to know when they have been counted
+			pass(inner, outer)
+			want := tt.in + tt.out + 1
+			eventually(t, "counts", func() bool {
+				in, out := c.Totals()
+				return in+out >= want
+			})
+
+			in, out := c.Totals()
+			if in != tt.in || out != tt.out+1 {
+				t.Errorf("counted %d in and %d out, want %d and %d", in, out-1, tt.in, tt.out)
+			}
+		})
+	}
+}
+
+func TestPeopleCounterIgnoresOtherEdge(t *testing.T) {
+	bus := gpio.NewBus()
+	c := &gpio.PeopleCounter{Outer: 1, Inner: 2, Within: time.Second, Bus: bus}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx, bus)
+
+	now := time.Now()
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		// Beams restored, not broken
+		bus.Publish(gpio.Event{Kind: gpio.EventChange, Channel: 1, Edge: gpio.EdgeRising, Time: now})
+		bus.Publish(gpio.Event{Kind: gpio.EventChange, Channel: 2, Edge: gpio.EdgeRising, Time: now.Add(time.Millisecond)})
+		now = now.Add(time.Hour)
+		time.Sleep(time.Millisecond)
+	}
+	if in, out := c.Totals(); in != 0 || out != 0 {
+		t.Errorf("counted %d in and %d out on rising edges", in, out)
+	}
+}
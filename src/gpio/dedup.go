@@ -0,0 +1,26 @@
+package gpio
+
+import (
+	"time"
+)
+
+// Passes events through, suppressing any which repeat the last event seen on
+// the same channel (same edge and value) within the window. Use it after
+// debouncing to stop flaky contacts, such as reed switches, chattering
+// downstream. The returned channel is closed when events is.
+func Deduplicate(events <-chan Event, window time.Duration) <-chan Event {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		last := map[uint8]Event{}
+		for e := range events {
+			prev, seen := last[e.Channel]
+			if seen && prev.Edge == e.Edge && prev.Value == e.Value && e.Time.Sub(prev.Time) < window {
+				continue
+			}
+			last[e.Channel] = e
+			out <- e
+		}
+	}()
+	return out
+}
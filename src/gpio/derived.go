@@ -0,0 +1,245 @@
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A boolean signal derived from several inputs with a small expression
+// language, for interlocks and similar glue. For example
+//
+//	door && !(locked || override) for > 2s
+//
+// Names refer to inputs, which are true when high. The operators are !, &&
+// and ||, with parentheses for grouping. An optional trailing "for > d"
+// (any duration time.ParseDuration accepts) only makes the signal true once
+// the condition has held for longer than d, or "for >= d" for at least d; it
+// goes false as soon as the condition does.
+type DerivedSignal struct {
+	label string
+	expr  exprNode
+	hold  time.Duration
+	// Whether holding for exactly hold is enough: for >=, or no for at all
+	inclusive bool
+	inputs    map[string]InputPin
+}
+
+// Parses expr, whose names must all be keys of inputs. Changes in the signal
+// are reported as events with the given label.
+func NewDerivedSignal(label, expr string, inputs map[string]InputPin) (*DerivedSignal, error) {
+	p := &exprParser{tokens: tokenizeExpr(expr)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DerivedSignal{label: label, expr: node, inclusive: true, inputs: inputs}
+	if p.peek() == "for" {
+		p.next()
+		op := p.next()
+		if op != ">" && op != ">=" {
+			return nil, fmt.Errorf("gpio: expected > after for, got %q", op)
+		}
+		d.inclusive = op == ">="
+		if d.hold, err = time.ParseDuration(p.next()); err != nil {
+			return nil, err
+		}
+	}
+	if tok := p.peek(); tok != "" {
+		return nil, fmt.Errorf("gpio: unexpected %q in expression", tok)
+	}
+
+	for _, name := range node.names(nil) {
+		if _, ok := inputs[name]; !ok {
+			return nil, fmt.Errorf("gpio: no input named %q", name)
+		}
+	}
+	return d, nil
+}
+
+// Evaluates the condition once, ignoring any "for" duration.
+func (d *DerivedSignal) Evaluate() (bool, error) {
+	values := map[string]bool{}
+	for name, pin := range d.inputs {
+		high, err := pin.IsHigh()
+		if err != nil {
+			return false, err
+		}
+		values[name] = high
+	}
+	return d.expr.eval(values), nil
+}
+
+// Polls the inputs every interval, publishing an EventDerived to bus (or
+// DefaultBus if nil) each time the signal changes. Runs until ctx is
+// cancelled or an input can't be read.
+func (d *DerivedSignal) Run(ctx context.Context, interval time.Duration, bus *Bus) error {
+	if interval <= 0 {
+		return fmt.Errorf("gpio: invalid poll interval %v", interval)
+	}
+	if bus == nil {
+		bus = DefaultBus
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var signal bool
+	var since time.Time
+	var sequence uint64
+	for {
+		condition, err := d.Evaluate()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !condition {
+			since = time.Time{}
+		} else if since.IsZero() {
+			since = now
+		}
+
+		held := now.Sub(since)
+		value := condition && (held > d.hold || d.inclusive && held == d.hold)
+		if value != signal {
+			signal = value
+			sequence++
+			e := Event{Kind: EventDerived, Label: d.label, Edge: EdgeFalling, Time: now, Sequence: sequence}
+			if value {
+				e.Edge = EdgeRising
+				e.Value = 1
+			}
+			bus.Publish(e)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type exprNode interface {
+	eval(values map[string]bool) bool
+	names(into []string) []string
+}
+
+type identNode string
+
+func (n identNode) eval(values map[string]bool) bool { return values[string(n)] }
+func (n identNode) names(into []string) []string     { return append(into, string(n)) }
+
+type notNode struct{ operand exprNode }
+
+func (n notNode) eval(values map[string]bool) bool { return !n.operand.eval(values) }
+func (n notNode) names(into []string) []string     { return n.operand.names(into) }
+
+type binaryNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (n binaryNode) eval(values map[string]bool) bool {
+	if n.and {
+		return n.left.eval(values) && n.right.eval(values)
+	}
+	return n.left.eval(values) || n.right.eval(values)
+}
+
+func (n binaryNode) names(into []string) []string {
+	return n.right.names(n.left.names(into))
+}
+
+func tokenizeExpr(s string) []string {
+	var tokens []string
+	for len(s) > 0 {
+		r := rune(s[0])
+		switch {
+		case unicode.IsSpace(r):
+			s = s[1:]
+		case strings.HasPrefix(s, "&&"), strings.HasPrefix(s, "||"), strings.HasPrefix(s, ">="):
+			tokens = append(tokens, s[:2])
+			s = s[2:]
+		case strings.ContainsRune("!()>", r):
+			tokens = append(tokens, s[:1])
+			s = s[1:]
+		default:
+			end := strings.IndexFunc(s, func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune("!()&|>", r)
+			})
+			if end < 0 {
+				end = len(s)
+			} else if end == 0 {
+				// A lone & or |
+				end = 1
+			}
+			tokens = append(tokens, s[:end])
+			s = s[end:]
+		}
+	}
+	return tokens
+}
+
+type exprParser struct {
+	tokens []string
+}
+
+func (p *exprParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *exprParser) next() string {
+	tok := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var right exprNode
+		right, err = p.parseAnd()
+		left = binaryNode{left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var right exprNode
+		right, err = p.parseUnary()
+		left = binaryNode{and: true, left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch tok := p.next(); {
+	case tok == "!":
+		operand, err := p.parseUnary()
+		return notNode{operand}, err
+	case tok == "(":
+		node, err := p.parseOr()
+		if err == nil && p.next() != ")" {
+			err = fmt.Errorf("gpio: missing ) in expression")
+		}
+		return node, err
+	case tok == "" || tok == "for" || strings.ContainsAny(tok, "&|)>"):
+		return nil, fmt.Errorf("gpio: expected a name in expression, got %q", tok)
+	default:
+		return identNode(tok), nil
+	}
+}
@@ -0,0 +1,159 @@
+package gpio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Root of the device tree as exposed by the kernel.
+var deviceTreeRoot = "/proc/device-tree"
+
+// Reads a string property from the device tree. Device tree strings are NUL
+// terminated, and string lists are NUL separated.
+func readDeviceTreeStrings(path string) ([]string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(deviceTreeRoot, path))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(b), "\x00"), "\x00"), nil
+}
+
+// The device tree path of the main GPIO controller, e.g. "/soc/gpio@7e200000"
+func gpioControllerPath() (string, error) {
+	alias, err := readDeviceTreeStrings("aliases/gpio")
+	if err != nil {
+		return "", err
+	}
+	return alias[0], nil
+}
+
+// Returns the line names the device tree assigns to the GPIO controller,
+// indexed by channel. Unnamed lines are empty strings.
+func LineNames() ([]string, error) {
+	controller, err := gpioControllerPath()
+	if err != nil {
+		return nil, err
+	}
+	return readDeviceTreeStrings(filepath.Join(controller, "gpio-line-names"))
+}
+
+// Looks up the channel for a device tree line name, such as "ID_SD" or
+// "GPIO17", so pins can be configured by name rather than number.
+func LookupLineName(name string) (uint8, error) {
+	names, err := LineNames()
+	if err != nil {
+		return 0, err
+	}
+	for channel, n := range names {
+		if n == name && channel <= 255 {
+			return uint8(channel), nil
+		}
+	}
+	return 0, fmt.Errorf("gpio: no line named %q", name)
+}
+
+// Reads a property holding a list of big-endian 32-bit cells.
+func readDeviceTreeCells(path string) ([]uint32, error) {
+	b, err := ioutil.ReadFile(filepath.Join(deviceTreeRoot, path))
+	if err != nil {
+		return nil, err
+	}
+	cells := make([]uint32, len(b)/4)
+	for i := range cells {
+		cells[i] = binary.BigEndian.Uint32(b[i*4:])
+	}
+	return cells, nil
+}
+
+// Nodes without a status property are enabled.
+func deviceTreeNodeEnabled(node string) bool {
+	status, err := readDeviceTreeStrings(filepath.Join(node, "status"))
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return status[0] == "okay" || status[0] == "ok"
+}
+
+// Returns the channels claimed by enabled peripherals in the device tree,
+// including any loaded overlays, mapped to the peripheral's alias (e.g.
+// "i2c1") or node name.
+//
+// A peripheral claims the pins listed in the "brcm,pins" property of the pin
+// groups its pinctrl-0 property refers to.
+func DeviceTreeClaims() (map[uint8]string, error) {
+	root, err := filepath.EvalSymlinks(deviceTreeRoot)
+	if err != nil {
+		return nil, err
+	}
+	controller, _ := gpioControllerPath()
+
+	phandles := map[uint32]string{}
+	var consumers []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		node, _ := filepath.Rel(root, filepath.Dir(path))
+		node = "/" + filepath.ToSlash(node)
+		switch info.Name() {
+		case "phandle":
+			cells, err := readDeviceTreeCells(node + "/phandle")
+			if err != nil {
+				return err
+			}
+			if len(cells) > 0 {
+				phandles[cells[0]] = node
+			}
+		case "pinctrl-0":
+			// The controller's own pinctrl-0 sets up its default pin states,
+			// which doesn't mean anything is using them.
+			if node != controller {
+				consumers = append(consumers, node)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	aliases, _ := ioutil.ReadDir(filepath.Join(root, "aliases"))
+	for _, alias := range aliases {
+		if target, err := readDeviceTreeStrings("aliases/" + alias.Name()); err == nil {
+			names[target[0]] = alias.Name()
+		}
+	}
+
+	claims := map[uint8]string{}
+	for _, node := range consumers {
+		if !deviceTreeNodeEnabled(node) {
+			continue
+		}
+		owner, ok := names[node]
+		if !ok {
+			owner = filepath.Base(node)
+		}
+		groups, err := readDeviceTreeCells(node + "/pinctrl-0")
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			pins, err := readDeviceTreeCells(phandles[group] + "/brcm,pins")
+			if err != nil {
+				// Not a BCM pin group
+				continue
+			}
+			for _, pin := range pins {
+				if pin <= 255 {
+					claims[uint8(pin)] = owner
+				}
+			}
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,129 @@
+package gpio
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A passive switch at the end of a long cable, read with a wetting pulse:
+// Wet is driven high for Pulse before each sample, pushing enough current
+// through the contact and a sense resistor to break through oxide that
+// would make it read open, then released again to save power and the
+// contact. Run samples it periodically, publishing changes as EventChange.
+type DryContact struct {
+	Label string
+	// The channel reported in events, normally Sense's
+	Channel uint8
+	Sense   InputPin
+	Wet     OutputPin
+	// How long the wetting current flows before sampling. A millisecond
+	// when zero.
+	Pulse time.Duration
+	// How often Run samples. 100ms when zero.
+	Interval time.Duration
+	// Where changes are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	value    int
+	known    bool
+	sequence uint64
+}
+
+// Wets the contact and samples it.
+func (c *DryContact) Read() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.read()
+}
+
+// Called with mu held.
+func (c *DryContact) read() (int, error) {
+	pulse := c.Pulse
+	if pulse <= 0 {
+		pulse = time.Millisecond
+	}
+
+	if err := c.Wet.SetHigh(); err != nil {
+		return 0, err
+	}
+	time.Sleep(pulse)
+	value, err := c.Sense.GetValue()
+	if err2 := c.Wet.SetLow(); err == nil {
+		err = err2
+	}
+	return value, err
+}
+
+// Samples the contact every Interval until ctx is cancelled, or a sample
+// fails. The first sample is published too, so subscribers learn the
+// starting state.
+func (c *DryContact) Run(ctx context.Context) error {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.sample(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Samples the contact, publishing a change after unlocking so subscribers
+// can Read.
+func (c *DryContact) sample() error {
+	e, changed, err := c.poll()
+	if err != nil || !changed {
+		return err
+	}
+
+	bus := c.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	bus.Publish(e)
+	return nil
+}
+
+// Samples the contact, returning the event to publish if it changed.
+func (c *DryContact) poll() (Event, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, err := c.read()
+	if err != nil {
+		return Event{}, false, err
+	}
+	if c.known && value == c.value {
+		return Event{}, false, nil
+	}
+	c.value, c.known = value, true
+	c.sequence++
+
+	edge := EdgeFalling
+	if value == 1 {
+		edge = EdgeRising
+	}
+	return Event{
+		Kind:     EventChange,
+		Label:    c.Label,
+		Channel:  c.Channel,
+		Edge:     edge,
+		Value:    value,
+		Time:     time.Now(),
+		Sequence: c.sequence,
+	}, true, nil
+}
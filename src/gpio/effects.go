@@ -0,0 +1,94 @@
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Shapes a transition: maps progress from 0.0-1.0 to a level from 0.0-1.0.
+type Easing func(t float64) float64
+
+func EaseLinear(t float64) float64 {
+	return t
+}
+
+func EaseInQuad(t float64) float64 {
+	return t * t
+}
+
+func EaseOutQuad(t float64) float64 {
+	return t * (2 - t)
+}
+
+func EaseInOutSine(t float64) float64 {
+	return (1 - math.Cos(math.Pi*t)) / 2
+}
+
+// The level of an animated output, from 0.0-1.0, elapsed into the animation.
+type Effect func(elapsed time.Duration) float64
+
+// Rises from 0 to 1 and falls back each period, shaped by easing.
+func Ease(period time.Duration, easing Easing) (Effect, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("gpio: invalid effect period %v", period)
+	}
+	return func(elapsed time.Duration) float64 {
+		t := 2 * float64(elapsed%period) / float64(period)
+		if t > 1 {
+			t = 2 - t
+		}
+		return easing(t)
+	}, nil
+}
+
+// Slow, smooth breathing, as on a sleeping laptop's status LED.
+func Breathe(period time.Duration) (Effect, error) {
+	return Ease(period, EaseInOutSine)
+}
+
+// A flash at the start of each period which dies away over decay, then
+// stays off for the rest of the period, e.g. for a heartbeat.
+func PulseEffect(period, decay time.Duration) (Effect, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("gpio: invalid effect period %v", period)
+	}
+	if decay <= 0 {
+		return nil, fmt.Errorf("gpio: invalid pulse decay %v", decay)
+	}
+	return func(elapsed time.Duration) float64 {
+		t := float64(elapsed%period) / float64(decay)
+		if t >= 1 {
+			return 0
+		}
+		return EaseInQuad(1 - t)
+	}, nil
+}
+
+// Runs effect on pin until ctx is cancelled, or setting the pin fails.
+func Animate(ctx context.Context, pin PWMPin, effect Effect) error {
+	ticker := time.NewTicker(fadeStep)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		if err := setDutyFraction(pin, effect(time.Since(start))); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sets pin to duty, from 0.0-1.0, as finely as it allows.
+func setDutyFraction(pin PWMPin, duty float64) error {
+	if d, ok := pin.(DutySetter); ok {
+		return d.SetDuty(duty)
+	}
+	return pin.SetPWM(int(duty*100 + 0.5))
+}
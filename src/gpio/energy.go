@@ -0,0 +1,193 @@
+package gpio
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A daily time window with its own rate, e.g. night-rate electricity.
+type Tariff struct {
+	Name string
+	// Offsets from local midnight. A window with Start after End runs past
+	// midnight, e.g. 23h to 7h.
+	Start, End time.Duration
+}
+
+func (t Tariff) contains(at time.Time) bool {
+	midnight := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	offset := at.Sub(midnight)
+	if t.Start <= t.End {
+		return offset >= t.Start && offset < t.End
+	}
+	return offset >= t.Start || offset < t.End
+}
+
+// Accumulates pulses from an S0 energy meter output, splitting the energy by
+// tariff. Totals are kept in pulses, so nothing is lost to rounding.
+type EnergyMeter struct {
+	// The meter's constant, e.g. 1000 impulses per kWh
+	PulsesPerKWh float64
+	// Checked in order; the first containing a pulse's time gets it
+	Tariffs []Tariff
+	// Where pulses outside every tariff go. "standard" when empty.
+	DefaultTariff string
+	// The channel the meter is wired to, and the edge of each pulse, for Run.
+	// EdgeRising when empty.
+	Channel uint8
+	Edge    Edge
+	// Where totals are saved, as JSON. Not saved when empty.
+	Path string
+	// How often Run saves the totals, to spare SD cards a write per pulse
+	SaveInterval time.Duration
+
+	mu     sync.Mutex
+	pulses map[string]uint64
+}
+
+func (m *EnergyMeter) tariff(t time.Time) string {
+	for _, tariff := range m.Tariffs {
+		if tariff.contains(t) {
+			return tariff.Name
+		}
+	}
+	if m.DefaultTariff == "" {
+		return "standard"
+	}
+	return m.DefaultTariff
+}
+
+// Counts a pulse at time t.
+func (m *EnergyMeter) Pulse(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pulses == nil {
+		m.pulses = map[string]uint64{}
+	}
+	m.pulses[m.tariff(t)]++
+}
+
+// The energy used under each tariff, in kWh
+func (m *EnergyMeter) KWh() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kwh := make(map[string]float64, len(m.pulses))
+	for tariff, n := range m.pulses {
+		kwh[tariff] = float64(n) / m.PulsesPerKWh
+	}
+	return kwh
+}
+
+// Reads the totals saved at Path, replacing any counted so far. A missing
+// file is not an error, so a new meter starts from zero.
+func (m *EnergyMeter) Load() error {
+	b, err := ioutil.ReadFile(m.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	pulses := map[string]uint64{}
+	if err := json.Unmarshal(b, &pulses); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.pulses = pulses
+	m.mu.Unlock()
+	return nil
+}
+
+// Writes the totals to Path, replacing the file atomically so a power cut
+// can't leave it half written.
+func (m *EnergyMeter) Save() error {
+	if m.Path == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	b, err := json.Marshal(m.pulses)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Synced before the rename, or the rename may reach the disk before the
+	// data does
+	tmp := m.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, m.Path); err != nil {
+		return err
+	}
+
+	// And the directory after, so the rename itself survives
+	dir, err := os.Open(filepath.Dir(m.Path))
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if err2 := dir.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Counts pulses on Channel from bus (or DefaultBus if nil) until ctx is
+// cancelled, saving every SaveInterval and once more on the way out.
+func (m *EnergyMeter) Run(ctx context.Context, bus *Bus) error {
+	if bus == nil {
+		bus = DefaultBus
+	}
+	edge := m.Edge
+	if edge == "" {
+		edge = EdgeRising
+	}
+
+	// A slow Save drops the oldest pulses rather than holding up the bus
+	sub := bus.SubscribeWithPolicy(Filter{Kinds: []EventKind{EventChange}, Channels: []uint8{m.Channel}, Edge: edge}, PolicyDropOldest)
+	defer sub.Close()
+
+	var save <-chan time.Time
+	if m.SaveInterval > 0 {
+		ticker := time.NewTicker(m.SaveInterval)
+		defer ticker.Stop()
+		save = ticker.C
+	}
+
+	for {
+		select {
+		case e := <-sub.Events():
+			m.Pulse(e.Time)
+		case <-save:
+			if err := m.Save(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			if err := m.Save(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrNotExported = errors.New("gpio: channel is not exported")
+	// Returned by operations on a pin after it is closed or released
+	ErrClosed = errors.New("gpio: pin is closed")
+	// Matches errors caused by the process not being allowed to use a
+	// channel, e.g. not being in the gpio group.
+	ErrPermission = errors.New("gpio: permission denied")
+	// Matches errors caused by another process or driver using a channel
+	ErrBusy = errors.New("gpio: channel is busy")
+)
+
+// An error from an operation on a pin, saying which pin and what was being
+// done. Use errors.Is with the sentinel errors, or errors.As to get the
+// details.
+type PinError struct {
+	Channel uint8
+	// e.g. "open", "read", "write"
+	Op  string
+	Err error
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("gpio: %s channel %d: %s", e.Op, e.Channel, strings.TrimPrefix(e.Err.Error(), "gpio: "))
+}
+
+func (e *PinError) Unwrap() error {
+	return e.Err
+}
+
+// Matches ErrPermission and ErrBusy from the underlying system errors.
+func (e *PinError) Is(target error) bool {
+	switch target {
+	case ErrPermission:
+		return errors.Is(e.Err, os.ErrPermission)
+	case ErrBusy:
+		return errors.Is(e.Err, syscall.EBUSY)
+	}
+	return false
+}
+
+// Wraps err from op on the pin in a PinError, unless it is nil or already
+// one.
+func (p *pin) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*PinError); ok {
+		return err
+	}
+	return &PinError{Channel: p.channel, Op: op, Err: err}
+}
@@ -0,0 +1,78 @@
+package gpio
+
+import (
+	"time"
+)
+
+// Which transitions of an input to report. The values match those accepted
+// by the sysfs edge file.
+type Edge string
+
+const (
+	EdgeNone    Edge = "none"
+	EdgeRising  Edge = "rising"
+	EdgeFalling Edge = "falling"
+	EdgeBoth    Edge = "both"
+)
+
+// What an event reports.
+type EventKind string
+
+const (
+	// A pin changed level
+	EventChange EventKind = "change"
+	// An operation or event delivery overran its latency budget. The
+	// measured time is in Event.Latency.
+	EventLatency EventKind = "latency"
+	// An alarm was raised (Value 1) or cleared (Value 0). The sample which
+	// caused it is in Event.Reading.
+	EventAlarm EventKind = "alarm"
+	// An input started (Value 1) or stopped (Value 0) changing abnormally
+	// fast. See ChatterDetector.
+	EventChatter EventKind = "chatter"
+	// A write was rejected by an Interlock. Label is the output and Value
+	// the level refused.
+	EventInterlock EventKind = "interlock"
+	// A PatternMatcher recognised the pattern named by Label
+	EventPattern EventKind = "pattern"
+	// A counter counted. Label says what, and the new total is in Reading.
+	EventCount EventKind = "count"
+	// A fault was latched (Value 1) or reset (Value 0). See SafetyInput and
+	// FaultManager.
+	EventFault EventKind = "fault"
+	// An output was overridden by hand (Value 1), or handed back to its
+	// automation (Value 0). Label is the output. See Overrides.
+	EventOverride EventKind = "override"
+	// A DerivedSignal named by Label changed, with Edge and Value as for
+	// EventChange
+	EventDerived EventKind = "derived"
+)
+
+// Something which happened on a pin. All event-producing parts of the
+// package report with this type, so consumers only need to handle one.
+type Event struct {
+	Kind EventKind
+	// Optional human-readable name for the pin, e.g. "door"
+	Label   string
+	Channel uint8
+	// The GPIO chip the channel belongs to, e.g. "gpiochip0". Empty for the
+	// sysfs interface, which numbers channels globally.
+	Chip string
+	// The transition which caused this event, EdgeRising or EdgeFalling
+	Edge Edge
+	// The new level
+	Value int
+	// When the event happened, taken as close to the source as the backend
+	// allows: the kernel's timestamp with Chardev, or wakeup with Sysfs. It
+	// carries a monotonic reading, so intervals between events can be
+	// measured with Sub even if the wall clock is stepped.
+	Time time.Time
+	// Increases by one for each event from the same source, so dropped
+	// events can be detected.
+	Sequence uint64
+
+	// How long the operation took, for EventLatency
+	Latency time.Duration
+	// The sampled value, for events from analog sources
+	Reading float64
+}
@@ -0,0 +1,140 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A set of outputs of which at most one may be high at a time, such as the
+// directions of a motorised valve or the ports of an antenna switch.
+// Switching breaks before making: the high output goes low, and the group
+// waits breakBeforeMake before setting the next one high.
+type ExclusiveGroup struct {
+	mu              sync.Mutex
+	pins            []OutputPin
+	active          int
+	breakBeforeMake time.Duration
+}
+
+// Creates a group of the pins, setting them all low.
+func NewExclusiveGroup(breakBeforeMake time.Duration, pins ...OutputPin) (*ExclusiveGroup, error) {
+	g := &ExclusiveGroup{pins: pins, active: -1, breakBeforeMake: breakBeforeMake}
+	for _, p := range pins {
+		if err := p.SetLow(); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Sets the i'th output high, and all the others low.
+func (g *ExclusiveGroup) Select(i int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.selectPin(i)
+}
+
+func (g *ExclusiveGroup) selectPin(i int) error {
+	if i < 0 || i >= len(g.pins) {
+		return fmt.Errorf("gpio: no output %d in group of %d", i, len(g.pins))
+	}
+	if g.active == i {
+		return nil
+	}
+
+	if g.active >= 0 {
+		if err := g.off(); err != nil {
+			return err
+		}
+		time.Sleep(g.breakBeforeMake)
+	}
+
+	if err := g.pins[i].SetHigh(); err != nil {
+		return err
+	}
+	g.active = i
+	return nil
+}
+
+// Sets all the outputs low.
+func (g *ExclusiveGroup) Off() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.off()
+}
+
+func (g *ExclusiveGroup) off() error {
+	if g.active < 0 {
+		return nil
+	}
+	if err := g.pins[g.active].SetLow(); err != nil {
+		return err
+	}
+	g.active = -1
+	return nil
+}
+
+// Which output is high, or -1 if none are.
+func (g *ExclusiveGroup) Active() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.active
+}
+
+// The i'th output, as a pin which goes through the group. Setting it high
+// selects it; setting it low switches it off if it was selected.
+func (g *ExclusiveGroup) Pin(i int) OutputPin {
+	return &exclusivePin{group: g, index: i}
+}
+
+// Closes all the pins in the group.
+func (g *ExclusiveGroup) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var err error
+	for _, p := range g.pins {
+		if err2 := p.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+type exclusivePin struct {
+	group *ExclusiveGroup
+	index int
+}
+
+func (p *exclusivePin) SetHigh() error {
+	return p.group.Select(p.index)
+}
+
+func (p *exclusivePin) SetLow() error {
+	p.group.mu.Lock()
+	defer p.group.mu.Unlock()
+
+	if p.group.active != p.index {
+		return nil
+	}
+	return p.group.off()
+}
+
+func (p *exclusivePin) Toggle() error {
+	p.group.mu.Lock()
+	defer p.group.mu.Unlock()
+
+	if p.group.active == p.index {
+		return p.group.off()
+	}
+	return p.group.selectPin(p.index)
+}
+
+// The group owns the pin, so this does nothing. Close the group instead.
+func (p *exclusivePin) Close() error {
+	return nil
+}
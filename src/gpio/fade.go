@@ -0,0 +1,73 @@
+package gpio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Reported by a fade cut short by another change to the pin, or it closing.
+var ErrFadeStopped = errors.New("gpio: fade stopped")
+
+// How often a fade moves the duty cycle
+const fadeStep = 10 * time.Millisecond
+
+// A fade in progress on a pin.
+type fade struct {
+	quit chan struct{}
+}
+
+// Stops the fade, if there is one. Called with the pin's lock held, so it
+// happens between steps.
+func (f *fade) stop() {
+	if f.quit != nil {
+		close(f.quit)
+		f.quit = nil
+	}
+}
+
+// Starts fading from ppm from to ppm to over the duration, replacing any
+// fade in progress. Each step calls set with mu held. Called with mu held.
+func (f *fade) start(mu sync.Locker, from, to int, over time.Duration, set func(ppm int) error) <-chan error {
+	f.stop()
+	quit := make(chan struct{})
+	f.quit = quit
+
+	result := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(fadeStep)
+		defer ticker.Stop()
+
+		start := time.Now()
+		for {
+			ppm := to
+			if elapsed := time.Since(start); elapsed < over {
+				ppm = from + int(float64(to-from)*float64(elapsed)/float64(over))
+			}
+
+			mu.Lock()
+			select {
+			case <-quit:
+				mu.Unlock()
+				result <- ErrFadeStopped
+				return
+			default:
+			}
+			err := set(ppm)
+			if err != nil || ppm == to {
+				// Finished, so later changes have nothing to stop
+				if f.quit == quit {
+					f.quit = nil
+				}
+			}
+			mu.Unlock()
+
+			if err != nil || ppm == to {
+				result <- err
+				return
+			}
+			<-ticker.C
+		}
+	}()
+	return result
+}
@@ -0,0 +1,43 @@
+package gpio
+
+// Shares one watch on a pin between several consumers, e.g. a debouncer, a
+// counter and a raw logger. The kernel only reports each edge once, and every
+// consumer sees the same events in the same order. A consumer falling behind
+// holds up the others, rather than missing events they got.
+type EdgeFanout struct {
+	pin  InputPin
+	bus  *Bus
+	done chan struct{}
+}
+
+// Starts watching both edges of pin, which mustn't already be watched.
+func NewEdgeFanout(pin InputPin) (*EdgeFanout, error) {
+	events, err := pin.Watch(EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &EdgeFanout{pin: pin, bus: NewBus(), done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		for e := range events {
+			f.bus.Publish(e)
+		}
+	}()
+	return f, nil
+}
+
+// Starts receiving events for the edge. Close the subscription when done
+// with it, so it stops holding up the others.
+func (f *EdgeFanout) Subscribe(edge Edge) *Subscription {
+	return f.bus.Subscribe(Filter{Edge: edge})
+}
+
+// Stops watching the pin, waiting for any event being delivered, so
+// subscribers must keep reading until it returns. Subscriptions receive no
+// more events afterwards, but must still be closed.
+func (f *EdgeFanout) Close() error {
+	_, err := f.pin.Watch(EdgeNone)
+	<-f.done
+	return err
+}
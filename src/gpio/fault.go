@@ -0,0 +1,229 @@
+package gpio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrFaulted = errors.New("gpio: inhibited by an active fault")
+
+// A latched fault raised by some part of a system.
+type Fault struct {
+	// Identifies the fault, e.g. "E12" or "pump.overtemp"
+	Code  string    `json:"code"`
+	Cause string    `json:"cause"`
+	Time  time.Time `json:"time"`
+}
+
+// Keeps faults raised by any part of a system latched until someone
+// acknowledges them, and holds inhibited outputs low meanwhile, as a machine
+// controller's fault handling does. Raising and acknowledging are published
+// as EventFault, labelled with the code.
+//
+// It is an http.Handler: GET lists the active faults as JSON, and POST
+// acknowledges the one given by the code form value, or all of them without
+// one.
+type FaultManager struct {
+	// Where faults are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	faults   map[string]Fault
+	outputs  []*inhibitedPin
+	sequence uint64
+	// Events waiting for mu to be unlocked
+	outbox []Event
+}
+
+// Latches a fault, driving every inhibited output low. Raising a code which
+// is already active updates its cause. Returns the first error from setting
+// the outputs low.
+func (m *FaultManager) Raise(code, cause string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.faults == nil {
+		m.faults = map[string]Fault{}
+	}
+	f, active := m.faults[code]
+	if !active {
+		f = Fault{Code: code, Time: time.Now()}
+	}
+	f.Cause = cause
+	m.faults[code] = f
+	if !active {
+		m.publish(code, 1)
+	}
+
+	var err error
+	for _, p := range m.outputs {
+		if err2 := p.pin.SetLow(); err2 != nil {
+			if err == nil {
+				err = err2
+			}
+			continue
+		}
+		p.level = 0
+	}
+	return err
+}
+
+// The active faults, oldest first
+func (m *FaultManager) Active() []Fault {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	faults := make([]Fault, 0, len(m.faults))
+	for _, f := range m.faults {
+		faults = append(faults, f)
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].Time.Before(faults[j].Time) })
+	return faults
+}
+
+// Clears the fault with code. Inhibited outputs are released once no faults
+// are left, but stay low until set again.
+func (m *FaultManager) Acknowledge(code string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if _, ok := m.faults[code]; !ok {
+		return fmt.Errorf("gpio: no active fault %q", code)
+	}
+	delete(m.faults, code)
+	m.publish(code, 0)
+	return nil
+}
+
+// Clears every active fault.
+func (m *FaultManager) AcknowledgeAll() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	for code := range m.faults {
+		delete(m.faults, code)
+		m.publish(code, 0)
+	}
+}
+
+// Queues an event for unlock. Called with mu held.
+func (m *FaultManager) publish(code string, value int) {
+	m.sequence++
+	m.outbox = append(m.outbox, Event{Kind: EventFault, Label: code, Value: value, Time: time.Now(), Sequence: m.sequence})
+}
+
+// Unlocks mu, then publishes the events queued while it was held, so a
+// subscriber that raises or acknowledges faults itself can't deadlock.
+func (m *FaultManager) unlock() {
+	events := m.outbox
+	m.outbox = nil
+	m.mu.Unlock()
+
+	bus := m.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	for _, e := range events {
+		bus.Publish(e)
+	}
+}
+
+// Sets pin low, and returns it wrapped so that it can't be set high while
+// any fault is active: SetHigh, and Toggle from low, fail with ErrFaulted.
+func (m *FaultManager) Inhibit(pin OutputPin) (OutputPin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := pin.SetLow(); err != nil {
+		return nil, err
+	}
+	p := &inhibitedPin{manager: m, pin: pin}
+	m.outputs = append(m.outputs, p)
+	return p, nil
+}
+
+// Sets the pin to value, or flips it if toggle is set, unless a fault
+// inhibits it.
+func (m *FaultManager) write(p *inhibitedPin, value int, toggle bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if toggle {
+		value = p.level ^ 1
+	}
+	if value == 1 && len(m.faults) > 0 {
+		return ErrFaulted
+	}
+
+	set := p.pin.SetLow
+	if value == 1 {
+		set = p.pin.SetHigh
+	}
+	if err := set(); err != nil {
+		return err
+	}
+	p.level = value
+	return nil
+}
+
+// Stops inhibiting p. Called with mu held.
+func (m *FaultManager) remove(p *inhibitedPin) {
+	for i, o := range m.outputs {
+		if o == p {
+			m.outputs = append(m.outputs[:i], m.outputs[i+1:]...)
+			return
+		}
+	}
+}
+
+type inhibitedPin struct {
+	manager *FaultManager
+	pin     OutputPin
+	// Guarded by the manager's mu
+	level int
+}
+
+func (p *inhibitedPin) SetHigh() error {
+	return p.manager.write(p, 1, false)
+}
+
+func (p *inhibitedPin) SetLow() error {
+	return p.manager.write(p, 0, false)
+}
+
+func (p *inhibitedPin) Toggle() error {
+	return p.manager.write(p, 0, true)
+}
+
+func (p *inhibitedPin) Close() error {
+	p.manager.mu.Lock()
+	p.manager.remove(p)
+	p.manager.mu.Unlock()
+
+	return p.pin.Close()
+}
+
+func (m *FaultManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Active())
+	case http.MethodPost:
+		code := r.FormValue("code")
+		if code == "" {
+			m.AcknowledgeAll()
+		} else if err := m.Acknowledge(code); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
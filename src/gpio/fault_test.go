@@ -0,0 +1,90 @@
+package gpio_test
+
+import (
+	"errors"
+	"testing"
+
+	"gpio"
+	"gpio/gpiotest"
+)
+
+func TestFaultManagerInhibit(t *testing.T) {
+	tests := []struct {
+		name        string
+		raise       []string
+		acknowledge []string
+		all         bool
+		// Whether the output may be set high afterwards
+		wantReleased bool
+	}{
+		{name: "no faults", wantReleased: true},
+		{name: "raised", raise: []string{"E1"}},
+		{name: "acknowledged", raise: []string{"E1"}, acknowledge: []string{"E1"}, wantReleased: true},
+		{name: "one of two acknowledged", raise: []string{"E1", "E2"}, acknowledge: []string{"E1"}},
+		{name: "raised twice", raise: []string{"E1", "E1"}, acknowledge: []string{"E1"}, wantReleased: true},
+		{name: "all acknowledged", raise: []string{"E1", "E2"}, all: true, wantReleased: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &gpio.FaultManager{Bus: gpio.NewBus()}
+			fake := gpiotest.NewOutputPin()
+			out, err := m.Inhibit(fake)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := out.SetHigh(); err != nil {
+				t.Fatalf("SetHigh before any fault: %v", err)
+			}
+
+			for _, code := range tt.raise {
+				if err := m.Raise(code, "test"); err != nil {
+					t.Fatal(err)
+				}
+				if got := fake.Value(); got != 0 {
+					t.Fatalf("output %d after Raise, want forced low", got)
+				}
+			}
+			for _, code := range tt.acknowledge {
+				if err := m.Acknowledge(code); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.all {
+				m.AcknowledgeAll()
+			}
+
+			// Released outputs stay low until set again
+			if len(tt.raise) > 0 && fake.Value() != 0 {
+				t.Fatal("output went high on acknowledging")
+			}
+
+			err = out.SetHigh()
+			if tt.wantReleased {
+				if err != nil {
+					t.Fatalf("SetHigh: %v", err)
+				}
+				if fake.Value() != 1 {
+					t.Error("output not high")
+				}
+				return
+			}
+			if !errors.Is(err, gpio.ErrFaulted) {
+				t.Fatalf("SetHigh() = %v, want ErrFaulted", err)
+			}
+			if fake.Value() != 0 {
+				t.Error("inhibited output went high")
+			}
+			if err := out.SetLow(); err != nil {
+				t.Errorf("SetLow while faulted: %v", err)
+			}
+		})
+	}
+}
+
+func TestFaultManagerAcknowledgeUnknown(t *testing.T) {
+	m := &gpio.FaultManager{Bus: gpio.NewBus()}
+	if err := m.Acknowledge("E1"); err == nil {
+		t.Error("acknowledged a fault never raised")
+	}
+}
@@ -0,0 +1,151 @@
+package gpio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A reading from a sampled or analog source.
+type Sample struct {
+	Value float64
+	Time  time.Time
+}
+
+// A stage in a sample processing pipeline. Filters keep state between
+// samples, so use a separate one for each stream.
+type SampleFilter interface {
+	Apply(s Sample) Sample
+}
+
+// Adapts a function to a SampleFilter.
+type SampleFilterFunc func(s Sample) Sample
+
+func (f SampleFilterFunc) Apply(s Sample) Sample {
+	return f(s)
+}
+
+// Runs samples through each filter in turn.
+func ChainFilters(filters ...SampleFilter) SampleFilter {
+	return SampleFilterFunc(func(s Sample) Sample {
+		for _, f := range filters {
+			s = f.Apply(s)
+		}
+		return s
+	})
+}
+
+// Applies a filter to a stream of samples. The returned channel is closed
+// when samples is.
+func FilterSamples(samples <-chan Sample, f SampleFilter) <-chan Sample {
+	out := make(chan Sample)
+	go func() {
+		defer close(out)
+		for s := range samples {
+			out <- f.Apply(s)
+		}
+	}()
+	return out
+}
+
+// Keeps the last n values seen.
+type window struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func (w *window) add(v float64) {
+	w.values[w.next] = v
+	w.next = (w.next + 1) % len(w.values)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+func newWindow(n int) (*window, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("gpio: invalid filter window %d", n)
+	}
+	return &window{values: make([]float64, n)}, nil
+}
+
+func (w *window) contents() []float64 {
+	if w.full {
+		return w.values
+	}
+	return w.values[:w.next]
+}
+
+// Averages the last n samples. n must be at least 1.
+func MovingAverage(n int) (SampleFilter, error) {
+	w, err := newWindow(n)
+	if err != nil {
+		return nil, err
+	}
+	return SampleFilterFunc(func(s Sample) Sample {
+		w.add(s.Value)
+		var sum float64
+		values := w.contents()
+		for _, v := range values {
+			sum += v
+		}
+		s.Value = sum / float64(len(values))
+		return s
+	}), nil
+}
+
+// Takes the median of the last n samples, which rejects occasional spikes.
+// n must be at least 1.
+func Median(n int) (SampleFilter, error) {
+	w, err := newWindow(n)
+	if err != nil {
+		return nil, err
+	}
+	return SampleFilterFunc(func(s Sample) Sample {
+		w.add(s.Value)
+		sorted := append([]float64(nil), w.contents()...)
+		sort.Float64s(sorted)
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			s.Value = (sorted[mid-1] + sorted[mid]) / 2
+		} else {
+			s.Value = sorted[mid]
+		}
+		return s
+	}), nil
+}
+
+// Exponentially weighted moving average. Alpha is the weight of the newest
+// sample, from 0-1; smaller values smooth more.
+func ExponentialSmoothing(alpha float64) SampleFilter {
+	var smoothed float64
+	started := false
+	return SampleFilterFunc(func(s Sample) Sample {
+		if started {
+			smoothed += alpha * (s.Value - smoothed)
+		} else {
+			smoothed = s.Value
+			started = true
+		}
+		s.Value = smoothed
+		return s
+	})
+}
+
+// Replaces each value with its rate of change per second since the previous
+// sample. The first sample has a rate of 0.
+func RateOfChange() SampleFilter {
+	var prev Sample
+	started := false
+	return SampleFilterFunc(func(s Sample) Sample {
+		out := s
+		out.Value = 0
+		if elapsed := s.Time.Sub(prev.Time).Seconds(); started && elapsed > 0 {
+			out.Value = (s.Value - prev.Value) / elapsed
+		}
+		prev = s
+		started = true
+		return out
+	})
+}
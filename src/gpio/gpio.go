@@ -1,11 +1,17 @@
+// Package gpio controls the GPIO pins of a Raspberry Pi.
+//
+// Pins are safe for concurrent use by multiple goroutines. Writes to a pin
+// are serialised, and a pin can be closed while other goroutines are using
+// it, after which their operations fail.
 package gpio
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"os"
-	"strconv"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,132 +32,341 @@ const (
 // on the header you should be using GPIO17 here.
 var GPIO_CHANNELS = []uint8{4, 17, 18, 21, 22, 23, 24, 25}
 
+type Direction string
+
+const (
+	DirectionIn  Direction = GPIO_IN
+	DirectionOut Direction = GPIO_OUT
+)
+
 type InputPin interface {
 	GetValue() (int, error)
 	IsHigh() (bool, error)
+	// Reads the pin samples times, interval apart, and returns the majority
+	// value. For lines too noisy for debouncing alone.
+	ReadStable(samples int, interval time.Duration) (int, error)
+	// Reports transitions on the given edge as they happen, without polling.
+	Watch(edge Edge) (<-chan Event, error)
+	// Blocks until a transition on the edge, or until timeout has passed
+	// without one, when it returns ErrTimeout. Zero waits forever.
+	WaitForEdge(edge Edge, timeout time.Duration) (Event, error)
+	// Enables the internal pull-up or pull-down resistor. Not all backends
+	// can; Sysfs returns ErrNotSupported.
+	SetPull(pull Pull) error
 	io.Closer
 }
 
 type OutputPin interface {
 	SetHigh() error
 	SetLow() error
+	// Flips the output from whatever it was last set to.
+	Toggle() error
 	io.Closer
 }
 
+// A pin which can switch between input and output while open, for protocols
+// like 1-Wire and DHT22 which turn the line around mid-transaction.
+type Pin interface {
+	InputPin
+	OutputPin
+	SetDirection(direction Direction) error
+}
+
+// Pins which can be closed without unexporting the channel, so outputs keep
+// their level after the process exits, e.g. across daemon restarts. Open the
+// pin again WithAdopt to pick it up without a glitch.
+type Releaser interface {
+	Release() error
+}
+
+// PWM pins which report failures of a background loop as they happen, such
+// as those from NewPWMPin. Otherwise they're only seen by the next call.
+type PWMErrorReporter interface {
+	Err() <-chan error
+}
+
+// PWM pins which take fractional duty cycles, such as those from NewPWMPin.
+type DutySetter interface {
+	// A fraction from 0.0-1.0
+	SetDuty(duty float64) error
+}
+
+// PWM pins which can ramp smoothly between duty cycles, such as those from
+// NewPWMPin and NewHardwarePWMPin.
+type Fader interface {
+	// Fades from the current duty cycle to value, from 0-100, over the
+	// duration. The channel receives nil when the fade finishes, or why it
+	// didn't: ErrFadeStopped if the pin was set or closed meanwhile.
+	FadeTo(value int, over time.Duration) <-chan error
+}
+
+// PWM pins whose frequency can be changed, such as those from NewPWMPin.
+type PWMFrequencySetter interface {
+	SetPWMFrequency(hz float64) error
+}
+
 type PWMPin interface {
 	// A percentage value from 0-100
 	SetPWM(value int) error
 	io.Closer
 }
 
-func NewInputPin(channel uint8) (InputPin, error) {
-	pin := &pin{
-		channel: channel,
-	}
-	if err := pin.init(); err != nil {
+func NewInputPin(channel uint8, options ...Option) (InputPin, error) {
+	pin := newPin(channel, options)
+	if err := pin.validate(); err != nil {
 		return nil, err
 	}
-
-	if err := pin.setMode(GPIO_IN); err != nil {
-		return nil, err
+	if err := pin.open(GPIO_IN); err != nil {
+		return nil, pin.wrap("open", err)
 	}
 
 	return pin, nil
 }
 
-func NewOutputPin(channel uint8) (OutputPin, error) {
-	pin := &pin{
-		channel: channel,
-	}
-	if err := pin.init(); err != nil {
+func NewOutputPin(channel uint8, options ...Option) (OutputPin, error) {
+	pin := newPin(channel, options)
+	if err := pin.validate(); err != nil {
 		return nil, err
 	}
-
-	if err := pin.setMode(GPIO_OUT); err != nil {
-		return nil, err
+	if err := pin.open(GPIO_OUT); err != nil {
+		return nil, pin.wrap("open", err)
 	}
 
 	return pin, nil
 }
 
-func NewPWMPin(channel uint8) (PWMPin, error) {
-	pin := &pin{
-		channel: channel,
-	}
-	if err := pin.init(); err != nil {
+func NewPin(channel uint8, direction Direction, options ...Option) (Pin, error) {
+	pin := newPin(channel, options)
+	if err := pin.validate(); err != nil {
 		return nil, err
 	}
+	if err := pin.open(direction); err != nil {
+		return nil, pin.wrap("open", err)
+	}
 
-	if err := pin.setMode(GPIO_OUT); err != nil {
+	return pin, nil
+}
+
+func NewPWMPin(channel uint8, options ...Option) (PWMPin, error) {
+	pin := newPin(channel, options)
+	if err := pin.validate(); err != nil {
 		return nil, err
 	}
+	if err := pin.open(GPIO_OUT); err != nil {
+		return nil, pin.wrap("open", err)
+	}
 
 	return pin, nil
 }
 
 type pin struct {
-	channel   uint8
-	valueFile *os.File
+	// The software PWM period in nanoseconds, read by the loop with atomics.
+	// First, to keep it 64-bit aligned for atomic access on ARM.
+	pwmPeriod int64
+
+	channel uint8
+	backend Backend
+	// Values are inverted between the pin and the backend
+	activeLow bool
+	drive     Drive
+	debounce  time.Duration
+	// Set when the backend can't do the drive mode itself, so it is
+	// emulated by switching direction. Guarded by writeMu.
+	emulateDrive bool
+	// Set once the pin is closed or released, read with atomics. Written
+	// with writeMu held, so no write can start after it is set.
+	closed int32
+
+	// Applied when the pin is opened
+	optionErr       error
+	pull            Pull
+	hasPull         bool
+	pullOptional    bool
+	edge            Edge
+	initialValue    int
+	hasInitialValue bool
+	adopt           bool
+
+	// Guards the last value written, for Toggle
+	writeMu sync.Mutex
+	written bool
+	value   int
+
+	// Guards the software PWM loop and watch state
+	mu sync.Mutex
+	// The PWM duty cycle in parts per million, read by the loop with atomics
+	pwmDuty int32
+	pwmQuit chan struct{}
+	pwmDone chan struct{}
+	// Why the loop stopped, if it failed
+	pwmErr error
+	// Also told as soon as the loop fails
+	pwmErrs chan error
+	// Runs the PWM instead of a loop of the pin's own, when set
+	scheduler *PWMScheduler
+	// Set while the duty cycle is fading
+	fade fade
+	// The duty cycle last set in parts per million, before gamma correction
+	pwmLevel int
+	// Maps duty cycles from 0.0-1.0 for the eye, when set
+	gamma func(float64) float64
+
+	// Set while the pin is being watched for edges
+	stopWatch func() error
+	// Set for pins opened with a context, to stop closing them when done
+	stopContext func() bool
+}
 
-	pwmLoop     chan int
-	quitPwmLoop chan chan error
+func newPin(channel uint8, options []Option) *pin {
+	p := &pin{
+		channel:   channel,
+		backend:   DefaultBackend,
+		pwmPeriod: int64(defaultPWMPeriod),
+		pwmErrs:   make(chan error, 1),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
 }
 
-func (p *pin) init() error {
-	var err error
+// Exports the channel and sets it up as the options say, unexporting it
+// again if that fails.
+func (p *pin) open(direction Direction) error {
+	if p.optionErr != nil {
+		return p.optionErr
+	}
 
-	if err = p.exportChannel(); err != nil {
+	var current Direction
+	if p.adopt {
+		b, ok := p.backend.(AdoptBackend)
+		if !ok {
+			return ErrNotSupported
+		}
+		var err error
+		if current, err = b.Adopt(p.channel); err != nil {
+			return err
+		}
+	} else if err := p.backend.Export(p.channel); err != nil {
 		return err
 	}
 
-	if p.valueFile, err = os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/value", p.channel), os.O_RDWR, 600); err != nil {
+	if err := p.setup(direction, current); err != nil {
+		p.backend.Unexport(p.channel)
 		return err
 	}
-
 	return nil
 }
 
-func (p *pin) exportChannel() error {
-	exportFile, err := os.OpenFile("/sys/class/gpio/export", os.O_WRONLY, 200)
-	if err != nil {
-		return err
+// Sets up an exported channel, leaving it as it is where it already has the
+// direction wanted.
+func (p *pin) setup(direction, current Direction) error {
+	changed := direction != current
+	if changed {
+		var err error
+		if b, ok := p.backend.(OutputBackend); ok && direction == DirectionOut && p.hasInitialValue {
+			err = b.SetOutput(p.channel, p.level(p.initialValue))
+		} else {
+			err = p.backend.SetDirection(p.channel, direction)
+		}
+		if err != nil {
+			return err
+		}
 	}
-	defer exportFile.Close()
 
-	// if this exists we have to unexport it first
-	_, err = os.Stat(fmt.Sprintf("/sys/class/gpio/gpio%d", p.channel))
-	if err == nil {
-		if err = p.unexportChannel(); err != nil {
+	if p.hasPull {
+		if err := p.SetPull(p.pull); err != nil && !(p.pullOptional && errors.Is(err, ErrNotSupported)) {
 			return err
 		}
-	} else {
-		if !os.IsNotExist(err) {
+	}
+
+	if direction == DirectionOut {
+		if err := p.setDrive(); err != nil {
 			return err
 		}
+		if p.hasInitialValue && changed {
+			p.writeMu.Lock()
+			err := p.write(p.initialValue)
+			p.writeMu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	_, err = exportFile.WriteString(fmt.Sprintf("%d", p.channel))
-	return err
+	if p.edge != "" && p.edge != EdgeNone {
+		events, err := p.Watch(p.edge)
+		if err != nil {
+			return err
+		}
+		// Nobody reads the channel; the events go to DefaultBus
+		go func() {
+			for range events {
+			}
+		}()
+	}
+	return nil
 }
 
-func (p *pin) unexportChannel() error {
-	unexportFile, err := os.OpenFile("/sys/class/gpio/unexport", os.O_WRONLY, 200)
-	if err != nil {
-		return err
+func (p *pin) setDrive() error {
+	if p.drive == DrivePushPull {
+		return nil
 	}
-	defer unexportFile.Close()
 
-	_, err = unexportFile.WriteString(fmt.Sprintf("%d", p.channel))
-	return err
+	if b, ok := p.backend.(DriveBackend); ok {
+		return b.SetDrive(p.channel, p.drive)
+	}
+
+	// Start released, until something is written
+	p.writeMu.Lock()
+	p.emulateDrive = true
+	p.writeMu.Unlock()
+	return p.backend.SetDirection(p.channel, DirectionIn)
+}
+
+// Converts between logical and physical levels
+func (p *pin) level(value int) int {
+	if p.activeLow {
+		return value ^ 1
+	}
+	return value
 }
 
 func (p *pin) GetValue() (int, error) {
-	b, err := ioutil.ReadAll(p.valueFile)
+	val, err := p.read()
 	if err != nil {
-		return 0, nil
+		return 0, p.wrap("read", err)
+	}
+	return p.level(val), nil
+}
+
+// Maximum tries for a debounced reading to hold, before giving up and
+// returning the latest
+const debounceTries = 10
+
+// Reads the physical level, debounced if the pin is.
+func (p *pin) read() (int, error) {
+	if p.isClosed() {
+		return 0, ErrClosed
 	}
 
-	return strconv.Atoi(string(b))
+	val, err := p.backend.Read(p.channel)
+	if err != nil || p.debounce <= 0 {
+		return val, err
+	}
+
+	for i := 0; i < debounceTries; i++ {
+		time.Sleep(p.debounce)
+		next, err := p.backend.Read(p.channel)
+		if err != nil {
+			return 0, err
+		}
+		if next == val {
+			break
+		}
+		val = next
+	}
+	return val, nil
 }
 
 func (p *pin) IsHigh() (bool, error) {
@@ -159,125 +374,396 @@ func (p *pin) IsHigh() (bool, error) {
 	return (val == 1), err
 }
 
+func (p *pin) ReadStable(samples int, interval time.Duration) (int, error) {
+	if samples < 1 {
+		samples = 1
+	}
+
+	var high, last int
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+
+		val, err := p.GetValue()
+		if err != nil {
+			return 0, err
+		}
+		high += val
+		last = val
+	}
+
+	switch {
+	case high*2 > samples:
+		return 1, nil
+	case high*2 < samples:
+		return 0, nil
+	}
+	// A tie goes to the latest reading
+	return last, nil
+}
+
+func (p *pin) SetDirection(direction Direction) error {
+	if p.isClosed() {
+		return p.wrap("set direction", ErrClosed)
+	}
+	if err := p.backend.SetDirection(p.channel, direction); err != nil {
+		return p.wrap("set direction", err)
+	}
+	if direction == DirectionOut {
+		return p.wrap("set drive", p.setDrive())
+	}
+	return nil
+}
+
+func (p *pin) SetPull(pull Pull) error {
+	if p.isClosed() {
+		return p.wrap("set pull", ErrClosed)
+	}
+	b, ok := p.backend.(PullBackend)
+	if !ok {
+		return p.wrap("set pull", ErrNotSupported)
+	}
+	return p.wrap("set pull", b.SetPull(p.channel, pull))
+}
+
 func (p *pin) SetHigh() error {
-	_, err := p.valueFile.WriteString(GPIO_ON)
-	return err
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	return p.wrap("write", p.write(1))
 }
 
 func (p *pin) SetLow() error {
-	_, err := p.valueFile.WriteString(GPIO_OFF)
-	return err
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	return p.wrap("write", p.write(0))
+}
+
+// Pins which haven't been written through this package are read back first,
+// so e.g. a pin left high by another process goes low.
+func (p *pin) Toggle() error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	if !p.written {
+		val, err := p.GetValue()
+		if err != nil {
+			return err
+		}
+		p.value = val
+	}
+	return p.wrap("write", p.write(p.value^1))
+}
+
+// Called with writeMu held.
+func (p *pin) write(value int) error {
+	if p.isClosed() {
+		return ErrClosed
+	}
+	if err := p.writePhysical(p.level(value)); err != nil {
+		return err
+	}
+	p.written = true
+	p.value = value
+	return nil
+}
+
+// Called with writeMu held.
+func (p *pin) writePhysical(physical int) error {
+	if !p.emulateDrive {
+		return p.backend.Write(p.channel, physical)
+	}
+
+	// Open-drain only ever drives low, and open-source only high. Otherwise
+	// the line is released by making it an input.
+	driven := (p.drive == DriveOpenDrain && physical == 0) || (p.drive == DriveOpenSource && physical == 1)
+	if !driven {
+		return p.backend.SetDirection(p.channel, DirectionIn)
+	}
+	// Sysfs won't take values for an input, so switch first
+	if err := p.backend.SetDirection(p.channel, DirectionOut); err != nil {
+		return err
+	}
+	return p.backend.Write(p.channel, physical)
 }
 
 func valueToDuration(value int, max time.Duration) time.Duration {
 	return (max / time.Duration(100)) * time.Duration(value)
 }
 
-func (p *pin) startPwmLoop(initialValue int) {
-	if p.pwmLoop != nil {
-		p.pwmLoop <- initialValue
-		return
+// 50Hz, which suits servos
+const defaultPWMPeriod = 20 * time.Millisecond
+
+// 10kHz. Software PWM is already inaccurate well before this.
+const minSoftwarePWMPeriod = 100 * time.Microsecond
+
+// The period for a PWM frequency, if it is a real one with a period of at
+// least min.
+func pwmPeriodFor(hz float64, min time.Duration) (time.Duration, error) {
+	if !(hz > 0) || math.IsInf(hz, 1) || time.Duration(float64(time.Second)/hz) < min {
+		return 0, fmt.Errorf("gpio: invalid PWM frequency %gHz", hz)
 	}
-	p.pwmLoop = make(chan int)
-	p.quitPwmLoop = make(chan chan error)
+	return time.Duration(float64(time.Second) / hz), nil
+}
 
-	go func() {
-		var err error
-		var period time.Duration = 20 * time.Millisecond
-		var highDuration time.Duration
-		var ticker *time.Ticker = time.NewTicker(period)
-		defer func() {
-			// cleanup
-			close(p.pwmLoop)
-			close(p.quitPwmLoop)
-
-			p.pwmLoop = nil
-			p.quitPwmLoop = nil
-
-			ticker.Stop()
-		}()
+// Runs software PWM until quit is closed, or a write fails.
+func (p *pin) pwmLoop(quit, done chan struct{}) {
+	defer close(done)
 
-		for {
+	period := time.Duration(atomic.LoadInt64(&p.pwmPeriod))
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		if next := time.Duration(atomic.LoadInt64(&p.pwmPeriod)); next != period {
+			period = next
+			ticker.Reset(period)
+		}
+		high := period * time.Duration(atomic.LoadInt32(&p.pwmDuty)) / 1e6
+
+		err := p.SetHigh()
+		if err == nil {
+			time.Sleep(high)
+			err = p.SetLow()
+		}
+		if err != nil {
+			p.pwmErr = err
 			select {
-			case v := <-p.pwmLoop:
-				switch {
-				case v == 0:
-					p.SetLow()
-					return
-				case v == 100:
-					p.SetHigh()
-					return
-				default:
-					highDuration = valueToDuration(v, period)
-				}
-			case reply := <-p.quitPwmLoop:
-				reply <- nil
-				return
-			case <-ticker.C:
-				err = p.SetHigh()
-				if err != nil {
-					reply := <-p.quitPwmLoop
-					reply <- err
-					return
-				}
-
-				time.Sleep(highDuration)
-
-				err = p.SetLow()
-				if err != nil {
-					reply := <-p.quitPwmLoop
-					reply <- err
-					return
-				}
+			case p.pwmErrs <- err:
+			default:
 			}
+			return
 		}
-	}()
-}
 
-func (p *pin) setMode(mode string) error {
-	directionFile, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", p.channel), os.O_WRONLY, 200)
-	if err != nil {
-		return err
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
 	}
-	defer directionFile.Close()
-
-	_, err = directionFile.WriteString(mode)
-	return err
 }
 
+// Stops the PWM loop if it is running, returning why it failed if it did.
+// Called with mu held.
 func (p *pin) stopPwmLoop() error {
-	if p.pwmLoop == nil {
+	if p.pwmQuit == nil {
 		return nil
 	}
-	reply := make(chan error)
-	p.quitPwmLoop <- reply
 
-	return <-reply
+	close(p.pwmQuit)
+	<-p.pwmDone
+	p.pwmQuit, p.pwmDone = nil, nil
+
+	err := p.pwmErr
+	p.pwmErr = nil
+	return err
 }
 
-// Set the percentage of power to this pwm port from 0-100
+// Set the percentage of power to this pwm port from 0-100. 0 and 100 stop
+// the PWM loop and hold the output low or high.
 func (p *pin) SetPWM(value int) error {
-	if value == 0 {
-		p.stopPwmLoop()
-		p.SetLow()
-	} else {
-		p.startPwmLoop(value)
+	return p.setDuty(value * 1e4)
+}
+
+// Set the fraction of each period the output is high, from 0.0-1.0, for
+// finer control than SetPWM's whole percentages.
+func (p *pin) SetDuty(duty float64) error {
+	return p.setDuty(int(duty*1e6 + 0.5))
+}
+
+func (p *pin) setDuty(ppm int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fade.stop()
+	return p.applyDuty(ppm)
+}
+
+// Called with mu held.
+func (p *pin) applyDuty(ppm int) error {
+	if p.isClosed() {
+		return p.wrap("pwm", ErrClosed)
+	}
+	if ppm < 0 {
+		ppm = 0
+	} else if ppm > 1e6 {
+		ppm = 1e6
+	}
+	p.pwmLevel = ppm
+	if p.gamma != nil {
+		ppm = int(p.gamma(float64(ppm)/1e6)*1e6 + 0.5)
+	}
+
+	if p.scheduler != nil {
+		return p.wrap("pwm", p.setScheduledDuty(ppm))
+	}
+
+	if ppm <= 0 || ppm >= 1e6 {
+		if err := p.stopPwmLoop(); err != nil {
+			return p.wrap("pwm", err)
+		}
+		if ppm <= 0 {
+			return p.SetLow()
+		}
+		return p.SetHigh()
+	}
+
+	atomic.StoreInt32(&p.pwmDuty, int32(ppm))
+
+	if p.pwmDone != nil {
+		// Report a failed loop, rather than leaving it silently stopped
+		select {
+		case <-p.pwmDone:
+			return p.wrap("pwm", p.stopPwmLoop())
+		default:
+			return nil
+		}
 	}
+
+	p.pwmQuit = make(chan struct{})
+	p.pwmDone = make(chan struct{})
+	go p.pwmLoop(p.pwmQuit, p.pwmDone)
+	return nil
+}
+
+// Ramps the duty cycle from where it is to value, from 0-100, over the
+// duration in the background. Setting the pin in the meantime stops the fade.
+func (p *pin) FadeTo(value int, over time.Duration) <-chan error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.fade.start(&p.mu, p.pwmLevel, value*1e4, over, p.applyDuty)
+}
+
+// Called with mu held.
+func (p *pin) setScheduledDuty(ppm int) error {
+	// Report a failure since the last call, leaving the pin unscheduled as a
+	// failed loop is left stopped
+	err := p.pwmErr
+	p.pwmErr = nil
+
+	if ppm <= 0 || ppm >= 1e6 {
+		p.scheduler.remove(p)
+		if err != nil {
+			return err
+		}
+		if ppm <= 0 {
+			return p.SetLow()
+		}
+		return p.SetHigh()
+	}
+
+	if err != nil {
+		return err
+	}
+	p.scheduler.set(p, ppm, p.scheduledPWMFailed)
+	return nil
+}
+
+// Told by the scheduler when a write fails and it drops the pin.
+func (p *pin) scheduledPWMFailed(err error) {
+	p.mu.Lock()
+	p.pwmErr = err
+	p.mu.Unlock()
+
+	select {
+	case p.pwmErrs <- err:
+	default:
+	}
+}
+
+// Receives the error whenever the software PWM loop stops because a write
+// failed. The next SetPWM also returns it, after which the loop can be
+// started again.
+func (p *pin) Err() <-chan error {
+	return p.pwmErrs
+}
+
+// Changes the software PWM frequency, taking effect from the next period.
+// Software PWM can't keep accurate timing much above 1kHz. Pins on a
+// PWMScheduler run at its frequency, so return ErrNotSupported.
+func (p *pin) SetPWMFrequency(hz float64) error {
+	period, err := pwmPeriodFor(hz, minSoftwarePWMPeriod)
+	if err != nil {
+		return p.wrap("pwm", err)
+	}
+	if p.scheduler != nil {
+		return p.wrap("pwm", ErrNotSupported)
+	}
+	atomic.StoreInt64(&p.pwmPeriod, int64(period))
 	return nil
 }
 
 // Tear-down this pin. Cleans up exported channels, and leaves the system in a
 // clean state.
 func (p *pin) Close() error {
-	var err error
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	if err = p.stopPwmLoop(); err != nil {
-		return err
+	if err := p.stop(); err != nil {
+		return p.wrap("close", err)
 	}
+	p.setClosed()
 
-	if err = p.valueFile.Close(); err != nil {
+	return p.wrap("close", p.backend.Unexport(p.channel))
+}
+
+// Closes the pin but leaves the channel exported, with outputs at their last
+// level. A PWM output is left wherever the loop stopped. Backends which
+// can't do this return ErrNotSupported, without closing the pin.
+func (p *pin) Release() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.backend.(ReleaseBackend)
+	if !ok {
+		return p.wrap("release", ErrNotSupported)
+	}
+
+	if err := p.stop(); err != nil {
+		return p.wrap("release", err)
+	}
+	p.setClosed()
+
+	return p.wrap("release", b.Release(p.channel))
+}
+
+// Makes later operations fail with ErrClosed. Backends such as MMap carry on
+// working after unexporting, so this is what stops them.
+func (p *pin) setClosed() {
+	p.writeMu.Lock()
+	atomic.StoreInt32(&p.closed, 1)
+	p.writeMu.Unlock()
+}
+
+func (p *pin) isClosed() bool {
+	return atomic.LoadInt32(&p.closed) != 0
+}
+
+// Stops everything running for the pin. Called with mu held.
+func (p *pin) stop() error {
+	if p.stopContext != nil {
+		p.stopContext()
+	}
+
+	p.fade.stop()
+
+	if p.scheduler != nil {
+		p.scheduler.remove(p)
+		if err := p.pwmErr; err != nil {
+			p.pwmErr = nil
+			return err
+		}
+	}
+
+	if err := p.stopPwmLoop(); err != nil {
 		return err
 	}
 
-	return p.unexportChannel()
+	if p.stopWatch != nil {
+		return p.stopWatch()
+	}
+	return nil
 }
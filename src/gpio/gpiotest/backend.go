@@ -0,0 +1,251 @@
+package gpiotest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gpio"
+)
+
+// A fake gpio.Backend which keeps channel state in memory. Outputs read back
+// what was written to them, and tests drive inputs with Set.
+type Backend struct {
+	mu       sync.Mutex
+	channels map[uint8]*channelState
+}
+
+type channelState struct {
+	exported bool
+	mode     gpio.Direction
+	value    int
+	pull     gpio.Pull
+	writes   []Write
+	edge     gpio.Edge
+	events   chan gpio.Event
+	sequence uint64
+}
+
+func NewBackend() *Backend {
+	return &Backend{channels: map[uint8]*channelState{}}
+}
+
+// Returns the state for channel, creating it if needed. Called with b.mu held.
+func (b *Backend) state(channel uint8) *channelState {
+	s, ok := b.channels[channel]
+	if !ok {
+		s = &channelState{}
+		b.channels[channel] = s
+	}
+	return s
+}
+
+// Returns the state for an exported channel. Called with b.mu held.
+func (b *Backend) exported(channel uint8) (*channelState, error) {
+	s := b.state(channel)
+	if !s.exported {
+		return nil, gpio.ErrNotExported
+	}
+	return s, nil
+}
+
+func (b *Backend) Export(channel uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state(channel).exported = true
+	return nil
+}
+
+func (b *Backend) Unexport(channel uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(channel)
+	s.exported = false
+	if s.events != nil {
+		close(s.events)
+		s.events = nil
+	}
+	return nil
+}
+
+// Stops any watch, leaving the channel exported as it is.
+func (b *Backend) Release(channel uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(channel)
+	if s.events != nil {
+		close(s.events)
+		s.events = nil
+	}
+	return nil
+}
+
+func (b *Backend) SetDirection(channel uint8, direction gpio.Direction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, err := b.exported(channel)
+	if err != nil {
+		return err
+	}
+	s.mode = direction
+	return nil
+}
+
+func (b *Backend) SetOutput(channel uint8, value int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, err := b.exported(channel)
+	if err != nil {
+		return err
+	}
+	if value != 0 {
+		value = 1
+	}
+	s.mode = gpio.DirectionOut
+	s.value = value
+	s.writes = append(s.writes, Write{Time: time.Now(), Value: value})
+	return nil
+}
+
+func (b *Backend) SetPull(channel uint8, pull gpio.Pull) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, err := b.exported(channel)
+	if err != nil {
+		return err
+	}
+	s.pull = pull
+	return nil
+}
+
+func (b *Backend) Read(channel uint8) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, err := b.exported(channel)
+	if err != nil {
+		return 0, err
+	}
+	return s.value, nil
+}
+
+func (b *Backend) Write(channel uint8, value int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, err := b.exported(channel)
+	if err != nil {
+		return err
+	}
+	if s.mode != gpio.DirectionOut {
+		return fmt.Errorf("gpiotest: write to channel %d, which is not an output", channel)
+	}
+	if value != 0 {
+		value = 1
+	}
+	s.value = value
+	s.writes = append(s.writes, Write{Time: time.Now(), Value: value})
+	return nil
+}
+
+// Events are buffered generously, so tests don't need to read them as they
+// go. Once the buffer is full, Set drops further events, as a kernel does.
+func (b *Backend) WatchEdge(channel uint8, edge gpio.Edge) (<-chan gpio.Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, err := b.exported(channel)
+	if err != nil {
+		return nil, err
+	}
+	if s.events != nil {
+		close(s.events)
+		s.events = nil
+	}
+	if edge == gpio.EdgeNone {
+		return nil, nil
+	}
+
+	s.edge = edge
+	s.events = make(chan gpio.Event, 1024)
+	return s.events, nil
+}
+
+// Drives a channel to value, as if from outside, reporting the edge to any
+// watcher.
+func (b *Backend) Set(channel uint8, value int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(channel)
+	if value == s.value {
+		return
+	}
+	s.value = value
+
+	edge := gpio.EdgeFalling
+	if value == 1 {
+		edge = gpio.EdgeRising
+	}
+	if s.events != nil && (s.edge == gpio.EdgeBoth || s.edge == edge) {
+		s.sequence++
+		e := gpio.Event{
+			Kind:     gpio.EventChange,
+			Channel:  channel,
+			Edge:     edge,
+			Value:    value,
+			Time:     time.Now(),
+			Sequence: s.sequence,
+		}
+		// Never blocks, as Set is called with mu held. The gap in sequence
+		// numbers shows a dropped event.
+		select {
+		case s.events <- e:
+		default:
+		}
+	}
+}
+
+// Everything written to the channel so far, oldest first.
+func (b *Backend) Writes(channel uint8) []Write {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Write(nil), b.state(channel).writes...)
+}
+
+func (b *Backend) Exported(channel uint8) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state(channel).exported
+}
+
+// The channel's direction, or empty if it was never set.
+func (b *Backend) Direction(channel uint8) gpio.Direction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state(channel).mode
+}
+
+// The pull last set on the channel
+func (b *Backend) Pull(channel uint8) gpio.Pull {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state(channel).pull
+}
+
+var (
+	_ gpio.Backend        = &Backend{}
+	_ gpio.PullBackend    = &Backend{}
+	_ gpio.ReleaseBackend = &Backend{}
+	_ gpio.OutputBackend  = &Backend{}
+)
@@ -0,0 +1,326 @@
+// Package gpiotest provides in-memory pins and a backend, so code using the
+// gpio package can be unit tested away from a Raspberry Pi.
+//
+// The fake pins record everything written to them, and inputs can be scripted
+// to change over time. Alternatively, open real pins on a fake Backend with
+// gpio.WithBackend.
+package gpiotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gpio"
+)
+
+// A value written to an output.
+type Write struct {
+	Time  time.Time
+	Value int
+}
+
+// A fake gpio.OutputPin which records writes.
+type OutputPin struct {
+	mu     sync.Mutex
+	writes []Write
+	closed bool
+}
+
+func NewOutputPin() *OutputPin {
+	return &OutputPin{}
+}
+
+func (p *OutputPin) record(value int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("gpiotest: write to closed pin")
+	}
+	p.writes = append(p.writes, Write{Time: time.Now(), Value: value})
+	return nil
+}
+
+func (p *OutputPin) SetHigh() error {
+	return p.record(1)
+}
+
+func (p *OutputPin) SetLow() error {
+	return p.record(0)
+}
+
+// Writes 1 if nothing has been written yet.
+func (p *OutputPin) Toggle() error {
+	p.mu.Lock()
+	value := 1
+	if len(p.writes) > 0 {
+		value = p.writes[len(p.writes)-1].Value ^ 1
+	}
+	p.mu.Unlock()
+
+	return p.record(value)
+}
+
+func (p *OutputPin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	return nil
+}
+
+// Everything written so far, oldest first.
+func (p *OutputPin) Writes() []Write {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Write(nil), p.writes...)
+}
+
+// The last value written, or -1 if there haven't been any writes.
+func (p *OutputPin) Value() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.writes) == 0 {
+		return -1
+	}
+	return p.writes[len(p.writes)-1].Value
+}
+
+func (p *OutputPin) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.closed
+}
+
+// A fake gpio.PWMPin which records the duty cycles set.
+type PWMPin struct {
+	mu     sync.Mutex
+	writes []Write
+	closed bool
+}
+
+func NewPWMPin() *PWMPin {
+	return &PWMPin{}
+}
+
+func (p *PWMPin) SetPWM(value int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("gpiotest: write to closed pin")
+	}
+	p.writes = append(p.writes, Write{Time: time.Now(), Value: value})
+	return nil
+}
+
+func (p *PWMPin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	return nil
+}
+
+// Every duty cycle set so far, oldest first.
+func (p *PWMPin) Writes() []Write {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Write(nil), p.writes...)
+}
+
+func (p *PWMPin) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.closed
+}
+
+// A change in a scripted input.
+type Step struct {
+	// How long after the previous step
+	After time.Duration
+	Value int
+}
+
+// A fake gpio.InputPin whose value is set by the test.
+type InputPin struct {
+	mu       sync.Mutex
+	value    int
+	pull     gpio.Pull
+	edge     gpio.Edge
+	events   chan gpio.Event
+	sequence uint64
+	closed   bool
+}
+
+// Creates an input reading value until changed.
+func NewInputPin(value int) *InputPin {
+	return &InputPin{value: value}
+}
+
+// Changes the input's value, reporting the edge to any watcher.
+func (p *InputPin) Set(value int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if value == p.value {
+		return
+	}
+	p.value = value
+
+	edge := gpio.EdgeFalling
+	if value == 1 {
+		edge = gpio.EdgeRising
+	}
+	if p.events != nil && (p.edge == gpio.EdgeBoth || p.edge == edge) {
+		p.sequence++
+		e := gpio.Event{
+			Kind:     gpio.EventChange,
+			Edge:     edge,
+			Value:    value,
+			Time:     time.Now(),
+			Sequence: p.sequence,
+		}
+		// Never blocks, as Set is called with mu held. The gap in sequence
+		// numbers shows a dropped event.
+		select {
+		case p.events <- e:
+		default:
+		}
+	}
+}
+
+// Plays the steps in the background, each After the previous one. The
+// returned channel is closed once the last step has been applied.
+func (p *InputPin) Script(steps ...Step) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, step := range steps {
+			time.Sleep(step.After)
+			p.Set(step.Value)
+		}
+	}()
+	return done
+}
+
+// The pull last set with SetPull
+func (p *InputPin) Pull() gpio.Pull {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.pull
+}
+
+func (p *InputPin) GetValue() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, fmt.Errorf("gpiotest: read from closed pin")
+	}
+	return p.value, nil
+}
+
+func (p *InputPin) IsHigh() (bool, error) {
+	val, err := p.GetValue()
+	return (val == 1), err
+}
+
+func (p *InputPin) ReadStable(samples int, interval time.Duration) (int, error) {
+	if samples < 1 {
+		samples = 1
+	}
+
+	var high, last int
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		val, err := p.GetValue()
+		if err != nil {
+			return 0, err
+		}
+		high += val
+		last = val
+	}
+
+	switch {
+	case high*2 > samples:
+		return 1, nil
+	case high*2 < samples:
+		return 0, nil
+	}
+	return last, nil
+}
+
+// Events are buffered generously, so tests don't need to read them as they
+// go. Once the buffer is full, Set drops further events, as a kernel does.
+func (p *InputPin) Watch(edge gpio.Edge) (<-chan gpio.Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.events != nil {
+		if edge == gpio.EdgeNone {
+			close(p.events)
+			p.events = nil
+			return nil, nil
+		}
+		return nil, gpio.ErrAlreadyWatching
+	}
+	if edge == gpio.EdgeNone {
+		return nil, nil
+	}
+
+	p.edge = edge
+	p.events = make(chan gpio.Event, 1024)
+	return p.events, nil
+}
+
+func (p *InputPin) WaitForEdge(edge gpio.Edge, timeout time.Duration) (gpio.Event, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	e, err := gpio.WaitForEdgeContext(ctx, p, edge)
+	if err == context.DeadlineExceeded {
+		err = gpio.ErrTimeout
+	}
+	return e, err
+}
+
+func (p *InputPin) SetPull(pull gpio.Pull) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pull = pull
+	return nil
+}
+
+func (p *InputPin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.events != nil {
+		close(p.events)
+		p.events = nil
+	}
+	p.closed = true
+	return nil
+}
+
+var (
+	_ gpio.InputPin  = &InputPin{}
+	_ gpio.OutputPin = &OutputPin{}
+	_ gpio.PWMPin    = &PWMPin{}
+)
@@ -0,0 +1,130 @@
+package gpio
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default upper bounds for LatencyHistogram buckets
+var DefaultLatencyBounds = []time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// A per-channel histogram of how long events took to reach userspace, from
+// the time the kernel stamped them. Use it to check whether timing
+// requirements are being met. Kernel timestamps come from the Chardev
+// backend; with Sysfs the event time is taken on wakeup, so this measures
+// little more than delivery through the package.
+type LatencyHistogram struct {
+	bounds []time.Duration
+
+	mu       sync.Mutex
+	channels map[uint8]*latencyCounts
+}
+
+type latencyCounts struct {
+	// counts[i] is the number of observations <= bounds[i]; the last entry
+	// counts those above every bound.
+	counts []uint64
+	sum    time.Duration
+	total  uint64
+}
+
+// Creates a histogram with the given bucket upper bounds, or
+// DefaultLatencyBounds if none are given.
+func NewLatencyHistogram(bounds ...time.Duration) *LatencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBounds
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Sort(durations(bounds))
+	return &LatencyHistogram{bounds: bounds, channels: map[uint8]*latencyCounts{}}
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// Records the delivery latency of an event which has just been received.
+func (h *LatencyHistogram) Observe(e Event) {
+	latency := time.Since(e.Time)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c, ok := h.channels[e.Channel]
+	if !ok {
+		c = &latencyCounts{counts: make([]uint64, len(h.bounds)+1)}
+		h.channels[e.Channel] = c
+	}
+
+	i := sort.Search(len(h.bounds), func(i int) bool { return latency <= h.bounds[i] })
+	c.counts[i]++
+	c.sum += latency
+	c.total++
+}
+
+// Passes events through, observing each. The returned channel is closed when
+// events is.
+func (h *LatencyHistogram) Events(events <-chan Event) <-chan Event {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		for e := range events {
+			h.Observe(e)
+			out <- e
+		}
+	}()
+	return out
+}
+
+// Writes the histogram in the Prometheus text exposition format, as a
+// histogram named name with a channel label, in seconds.
+func (h *LatencyHistogram) WritePrometheus(w io.Writer, name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# TYPE %s histogram\n", name); err != nil {
+		return err
+	}
+
+	channels := make([]int, 0, len(h.channels))
+	for channel := range h.channels {
+		channels = append(channels, int(channel))
+	}
+	sort.Ints(channels)
+
+	for _, channel := range channels {
+		c := h.channels[uint8(channel)]
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += c.counts[i]
+			if _, err := fmt.Fprintf(w, "%s_bucket{channel=\"%d\",le=\"%g\"} %d\n", name, channel, bound.Seconds(), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{channel=\"%d\",le=\"+Inf\"} %d\n", name, channel, c.total); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum{channel=\"%d\"} %g\n", name, channel, c.sum.Seconds()); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_count{channel=\"%d\"} %d\n", name, channel, c.total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Opens a hardware PWM channel through /sys/class/pwm, e.g. chip 0 channel 0
+// for GPIO18 and channel 1 for GPIO19 once the pwm-2chan overlay is loaded.
+// The timing comes from the PWM peripheral rather than a goroutine, so servos
+// don't buzz from jitter. The output starts at 50Hz and 0%.
+func NewHardwarePWMPin(chip, channel int) (PWMPin, error) {
+	h := &hardwarePWMPin{
+		dir:    fmt.Sprintf("/sys/class/pwm/pwmchip%d/pwm%d", chip, channel),
+		period: defaultPWMPeriod,
+	}
+
+	if _, err := os.Stat(h.dir); os.IsNotExist(err) {
+		if err := writeSysfsFile(fmt.Sprintf("/sys/class/pwm/pwmchip%d/export", chip), fmt.Sprint(channel)); err != nil {
+			return nil, err
+		}
+		h.unexport = fmt.Sprintf("/sys/class/pwm/pwmchip%d/unexport", chip)
+		h.channel = channel
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := h.setup(); err != nil {
+		if h.unexport != "" {
+			writeSysfsFile(h.unexport, fmt.Sprint(h.channel))
+		}
+		return nil, err
+	}
+	return h, nil
+}
+
+// Starts the output at 0%.
+func (h *hardwarePWMPin) setup() error {
+	// Zero the duty cycle first, as the period can't be set below it
+	if err := h.write("duty_cycle", 0); err != nil {
+		return err
+	}
+	if err := h.write("period", int64(h.period)); err != nil {
+		return err
+	}
+	return writeSysfsFile(h.dir+"/enable", "1")
+}
+
+type hardwarePWMPin struct {
+	dir string
+	// Set when the channel was exported here, so Close unexports it
+	unexport string
+	channel  int
+
+	mu     sync.Mutex
+	period time.Duration
+	ppm    int64
+	fade   fade
+	closed bool
+}
+
+func (h *hardwarePWMPin) write(file string, value int64) error {
+	return writeSysfsFile(h.dir+"/"+file, fmt.Sprint(value))
+}
+
+// Set the percentage of each period the output is high, from 0-100
+func (h *hardwarePWMPin) SetPWM(value int) error {
+	return h.setDuty(int64(value) * 1e4)
+}
+
+// Set the fraction of each period the output is high, from 0.0-1.0
+func (h *hardwarePWMPin) SetDuty(duty float64) error {
+	return h.setDuty(int64(duty*1e6 + 0.5))
+}
+
+func (h *hardwarePWMPin) setDuty(ppm int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.fade.stop()
+	return h.applyDuty(ppm)
+}
+
+// Called with mu held.
+func (h *hardwarePWMPin) applyDuty(ppm int64) error {
+	if h.closed {
+		return ErrClosed
+	}
+	if ppm < 0 {
+		ppm = 0
+	} else if ppm > 1e6 {
+		ppm = 1e6
+	}
+
+	if err := h.write("duty_cycle", int64(h.period)*ppm/1e6); err != nil {
+		return err
+	}
+	h.ppm = ppm
+	return nil
+}
+
+// Ramps the duty cycle from where it is to value, from 0-100, over the
+// duration in the background. Setting the pin in the meantime stops the fade.
+func (h *hardwarePWMPin) FadeTo(value int, over time.Duration) <-chan error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.fade.start(&h.mu, int(h.ppm), value*1e4, over, func(ppm int) error {
+		return h.applyDuty(int64(ppm))
+	})
+}
+
+// Changes the frequency, keeping the duty cycle.
+func (h *hardwarePWMPin) SetPWMFrequency(hz float64) error {
+	period, err := pwmPeriodFor(hz, time.Nanosecond)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return ErrClosed
+	}
+	if err := h.write("duty_cycle", 0); err != nil {
+		return err
+	}
+	if err := h.write("period", int64(period)); err != nil {
+		return err
+	}
+	h.period = period
+	return h.write("duty_cycle", int64(period)*h.ppm/1e6)
+}
+
+// Disables the output, and unexports the channel if it was exported when
+// opened.
+func (h *hardwarePWMPin) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return ErrClosed
+	}
+	h.closed = true
+	h.fade.stop()
+
+	err := writeSysfsFile(h.dir+"/enable", "0")
+	if h.unexport != "" {
+		if err2 := writeSysfsFile(h.unexport, fmt.Sprint(h.channel)); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
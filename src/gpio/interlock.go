@@ -0,0 +1,167 @@
+package gpio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInterlock = errors.New("gpio: interlock violated")
+
+// Pin may only be high while Other is at Level (0 or 1).
+type InterlockRule struct {
+	Pin   string
+	Other string
+	Level int
+}
+
+func (r InterlockRule) String() string {
+	level := "low"
+	if r.Level == 1 {
+		level = "high"
+	}
+	return fmt.Sprintf("%s requires %s %s", r.Pin, r.Other, level)
+}
+
+// Enforces rules between named outputs on every write, for machine control,
+// e.g. that a heater may only be on while the fan is. A write which would
+// break a rule, by either pin in it, is rejected with ErrInterlock and
+// published to the bus as an EventInterlock.
+type Interlock struct {
+	Rules []InterlockRule
+	// Where violations are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	levels   map[string]int
+	sequence uint64
+}
+
+// Reads rules from r, one per line in the form "heater requires fan high".
+// Blank lines and lines starting with # are ignored.
+func LoadInterlockRules(r io.Reader) ([]InterlockRule, error) {
+	var rules []InterlockRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 4 || fields[1] != "requires" || (fields[3] != "low" && fields[3] != "high") {
+			return nil, fmt.Errorf("gpio: interlock line %d: expected pin requires other low|high", line)
+		}
+		rule := InterlockRule{Pin: fields[0], Other: fields[2]}
+		if fields[3] == "high" {
+			rule.Level = 1
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// Sets pin low, and returns it wrapped so that writes through it are checked
+// against the rules. name is how the rules refer to it.
+func (i *Interlock) Output(name string, pin OutputPin) (OutputPin, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.levels == nil {
+		i.levels = map[string]int{}
+	}
+	if err := pin.SetLow(); err != nil {
+		return nil, err
+	}
+	i.levels[name] = 0
+	return &interlockedPin{interlock: i, name: name, pin: pin}, nil
+}
+
+// Returns the first rule broken if name were set to value. Pins which
+// haven't been registered count as low. Called with mu held.
+func (i *Interlock) check(name string, value int) (InterlockRule, bool) {
+	level := func(pin string) int {
+		if pin == name {
+			return value
+		}
+		return i.levels[pin]
+	}
+
+	for _, r := range i.Rules {
+		if r.Pin != name && r.Other != name {
+			continue
+		}
+		if level(r.Pin) == 1 && level(r.Other) != r.Level {
+			return r, false
+		}
+	}
+	return InterlockRule{}, true
+}
+
+// Sets the pin to value, or flips it if toggle is set, if the rules allow it.
+func (i *Interlock) write(p *interlockedPin, value int, toggle bool) error {
+	i.mu.Lock()
+
+	name := p.name
+	if toggle {
+		value = i.levels[name] ^ 1
+	}
+
+	if r, ok := i.check(name, value); !ok {
+		i.sequence++
+		e := Event{
+			Kind:     EventInterlock,
+			Label:    name,
+			Value:    value,
+			Time:     time.Now(),
+			Sequence: i.sequence,
+		}
+		i.mu.Unlock()
+
+		// Published unlocked, so a subscriber writing to an interlocked
+		// output can't deadlock
+		bus := i.Bus
+		if bus == nil {
+			bus = DefaultBus
+		}
+		bus.Publish(e)
+		return fmt.Errorf("%w: %v", ErrInterlock, r)
+	}
+	defer i.mu.Unlock()
+
+	set := p.pin.SetLow
+	if value == 1 {
+		set = p.pin.SetHigh
+	}
+	if err := set(); err != nil {
+		return err
+	}
+	i.levels[name] = value
+	return nil
+}
+
+type interlockedPin struct {
+	interlock *Interlock
+	name      string
+	pin       OutputPin
+}
+
+func (p *interlockedPin) SetHigh() error {
+	return p.interlock.write(p, 1, false)
+}
+
+func (p *interlockedPin) SetLow() error {
+	return p.interlock.write(p, 0, false)
+}
+
+func (p *interlockedPin) Toggle() error {
+	return p.interlock.write(p, 0, true)
+}
+
+func (p *interlockedPin) Close() error {
+	return p.pin.Close()
+}
@@ -0,0 +1,113 @@
+package gpio_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gpio"
+	"gpio/gpiotest"
+)
+
+func TestInterlock(t *testing.T) {
+	rules, err := gpio.LoadInterlockRules(strings.NewReader(`
+# Only heat with the fan running, and never open both valves
+heater requires fan high
+inlet requires drain low
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type write struct {
+		pin   string
+		value int
+		// Whether the interlock refuses it
+		refused bool
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		// The outputs' levels afterwards
+		want map[string]int
+	}{
+		{
+			name:   "heater without fan",
+			writes: []write{{"heater", 1, true}},
+			want:   map[string]int{"heater": 0, "fan": 0},
+		},
+		{
+			name:   "heater with fan",
+			writes: []write{{"fan", 1, false}, {"heater", 1, false}},
+			want:   map[string]int{"heater": 1, "fan": 1},
+		},
+		{
+			name:   "fan stopped under heater",
+			writes: []write{{"fan", 1, false}, {"heater", 1, false}, {"fan", 0, true}},
+			want:   map[string]int{"heater": 1, "fan": 1},
+		},
+		{
+			name:   "heater then fan off",
+			writes: []write{{"fan", 1, false}, {"heater", 1, false}, {"heater", 0, false}, {"fan", 0, false}},
+			want:   map[string]int{"heater": 0, "fan": 0},
+		},
+		{
+			name:   "both valves",
+			writes: []write{{"drain", 1, false}, {"inlet", 1, true}},
+			want:   map[string]int{"inlet": 0, "drain": 1},
+		},
+		{
+			name:   "unrelated output",
+			writes: []write{{"light", 1, false}},
+			want:   map[string]int{"light": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := gpio.NewBus()
+			violations := bus.SubscribeWithPolicy(gpio.Filter{Kinds: []gpio.EventKind{gpio.EventInterlock}}, gpio.PolicyDropNewest)
+			defer violations.Close()
+
+			il := &gpio.Interlock{Rules: rules, Bus: bus}
+			fakes := map[string]*gpiotest.OutputPin{}
+			outputs := map[string]gpio.OutputPin{}
+			for _, name := range []string{"heater", "fan", "inlet", "drain", "light"} {
+				fakes[name] = gpiotest.NewOutputPin()
+				out, err := il.Output(name, fakes[name])
+				if err != nil {
+					t.Fatal(err)
+				}
+				outputs[name] = out
+			}
+
+			refused := 0
+			for _, w := range tt.writes {
+				set := outputs[w.pin].SetLow
+				if w.value == 1 {
+					set = outputs[w.pin].SetHigh
+				}
+				err := set()
+				if got := errors.Is(err, gpio.ErrInterlock); got != w.refused {
+					t.Fatalf("setting %s to %d: got %v, want refused %v", w.pin, w.value, err, w.refused)
+				}
+				if w.refused {
+					refused++
+					e := <-violations.Events()
+					if e.Label != w.pin || e.Value != w.value {
+						t.Errorf("got event %+v, want %s refused %d", e, w.pin, w.value)
+					}
+				}
+			}
+			if n := len(violations.Events()); n != 0 {
+				t.Errorf("%d unexpected violation events", n)
+			}
+
+			for name, want := range tt.want {
+				if got := fakes[name].Value(); got != want {
+					t.Errorf("%s is %d, want %d", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package gpio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A latency budget for a time-critical pin. Operations on pins wrapped by the
+// budget, and events passed through it, are timed, and an EventLatency is
+// published whenever one overruns. Frequent warnings mean the system is too
+// loaded for reliable bit-banging.
+type LatencyBudget struct {
+	// First, to keep it 64-bit aligned for atomic access on ARM
+	sequence uint64
+
+	Budget time.Duration
+	// Identify the pin in warnings
+	Channel uint8
+	Label   string
+	// Where warnings are published. DefaultBus when nil.
+	Bus *Bus
+}
+
+// Publishes a warning if more than the budget has passed since start.
+func (l *LatencyBudget) Check(start time.Time) {
+	latency := time.Since(start)
+	if latency <= l.Budget {
+		return
+	}
+
+	bus := l.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	bus.Publish(Event{
+		Kind:     EventLatency,
+		Label:    l.Label,
+		Channel:  l.Channel,
+		Time:     start,
+		Sequence: atomic.AddUint64(&l.sequence, 1),
+		Latency:  latency,
+	})
+}
+
+// Times reads from the pin against the budget.
+func (l *LatencyBudget) Input(pin InputPin) InputPin {
+	return &budgetedInputPin{InputPin: pin, budget: l}
+}
+
+// Times writes to the pin against the budget.
+func (l *LatencyBudget) Output(pin OutputPin) OutputPin {
+	return &budgetedOutputPin{OutputPin: pin, budget: l}
+}
+
+// Passes events through, checking how long each took to be delivered since
+// it happened. The returned channel is closed when events is.
+func (l *LatencyBudget) Events(events <-chan Event) <-chan Event {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		for e := range events {
+			l.Check(e.Time)
+			out <- e
+		}
+	}()
+	return out
+}
+
+type budgetedInputPin struct {
+	InputPin
+	budget *LatencyBudget
+}
+
+func (b *budgetedInputPin) GetValue() (int, error) {
+	defer b.budget.Check(time.Now())
+	return b.InputPin.GetValue()
+}
+
+func (b *budgetedInputPin) IsHigh() (bool, error) {
+	defer b.budget.Check(time.Now())
+	return b.InputPin.IsHigh()
+}
+
+type budgetedOutputPin struct {
+	OutputPin
+	budget *LatencyBudget
+}
+
+func (b *budgetedOutputPin) SetHigh() error {
+	defer b.budget.Check(time.Now())
+	return b.OutputPin.SetHigh()
+}
+
+func (b *budgetedOutputPin) SetLow() error {
+	defer b.budget.Check(time.Now())
+	return b.OutputPin.SetLow()
+}
+
+func (b *budgetedOutputPin) Toggle() error {
+	defer b.budget.Check(time.Now())
+	return b.OutputPin.Toggle()
+}
@@ -0,0 +1,241 @@
+package gpio
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+)
+
+// Sends notifications to people, e.g. by push message or email.
+type Notifier interface {
+	Notify(ctx context.Context, title, message string) error
+}
+
+// Publishes to an ntfy topic, e.g. "https://ntfy.sh/my-doorbell".
+func NewNtfyNotifier(topicURL string) Notifier {
+	return ntfyNotifier{url: topicURL}
+}
+
+type ntfyNotifier struct {
+	url string
+}
+
+func (n ntfyNotifier) Notify(ctx context.Context, title, message string) error {
+	req, err := http.NewRequest("POST", n.url, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title)
+	return send(ctx, req, "ntfy")
+}
+
+// Sends Pushover messages to user from the application with the given token.
+func NewPushoverNotifier(token, user string) Notifier {
+	return pushoverNotifier{token: token, user: user}
+}
+
+type pushoverNotifier struct {
+	token, user string
+}
+
+func (p pushoverNotifier) Notify(ctx context.Context, title, message string) error {
+	form := url.Values{
+		"token":   {p.token},
+		"user":    {p.user},
+		"title":   {title},
+		"message": {message},
+	}
+	req, err := http.NewRequest("POST", "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return send(ctx, req, "pushover")
+}
+
+func send(ctx context.Context, req *http.Request, service string) error {
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("gpio: %s: %s", service, resp.Status)
+	}
+	return nil
+}
+
+// Emails the notifications through the SMTP server at addr ("host:port").
+// auth may be nil for servers which don't need it.
+func NewSMTPNotifier(addr string, auth smtp.Auth, from string, to ...string) Notifier {
+	return smtpNotifier{addr: addr, auth: auth, from: from, to: to}
+}
+
+type smtpNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+// Does what smtp.SendMail does, but on a connection closed if ctx is done.
+func (s smtpNotifier) Notify(ctx context.Context, title, message string) error {
+	// Line breaks in the title would start new headers
+	title = strings.Join(strings.Fields(title), " ")
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		s.from, strings.Join(s.to, ", "), mime.QEncoding.Encode("utf-8", title), message)
+
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := s.send(conn, host, msg); err != nil {
+		// Report cancellation rather than the closed connection's error
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func (s smtpNotifier) send(conn net.Conn, host, msg string) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(s.auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Sends a notification for each matching event, such as alarms or a
+// doorbell press.
+type NotifyHook struct {
+	Filter   Filter
+	Notifier Notifier
+	// Makes the title and message for an event. DescribeEvent is used when
+	// nil.
+	Format func(e Event) (title, message string)
+	// Called when a notification can't be sent
+	OnError func(e Event, err error)
+}
+
+// Notifies for events from bus (or DefaultBus if nil) until ctx is
+// cancelled. Notifications are sent one at a time; events arriving faster
+// are dropped rather than holding up the bus.
+func (h *NotifyHook) Run(ctx context.Context, bus *Bus) error {
+	if bus == nil {
+		bus = DefaultBus
+	}
+
+	format := h.Format
+	if format == nil {
+		format = DescribeEvent
+	}
+
+	sub := bus.SubscribeWithPolicy(h.Filter, PolicyDropNewest)
+	defer sub.Close()
+
+	for {
+		select {
+		case e := <-sub.Events():
+			title, message := format(e)
+			if err := h.Notifier.Notify(ctx, title, message); err != nil && h.OnError != nil {
+				h.OnError(e, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Describes an event plainly: the label (or channel) as the title, and what
+// happened and when as the message, e.g. "door: rising at 2006-01-02 15:04:05".
+func DescribeEvent(e Event) (title, message string) {
+	title = e.Label
+	if title == "" {
+		title = fmt.Sprintf("GPIO %d", e.Channel)
+	}
+
+	switch e.Kind {
+	case EventAlarm:
+		state := "cleared"
+		if e.Value == 1 {
+			state = "raised"
+		}
+		message = fmt.Sprintf("alarm %s (%g)", state, e.Reading)
+	case EventChatter:
+		message = "chattering"
+		if e.Value == 0 {
+			message = "stable"
+		}
+	case EventFault:
+		message = "fault"
+		if e.Value == 0 {
+			message = "fault reset"
+		}
+	case EventOverride:
+		message = "overridden"
+		if e.Value == 0 {
+			message = "override ended"
+		}
+	case EventInterlock:
+		message = fmt.Sprintf("interlock refused setting it to %d", e.Value)
+	case EventPattern:
+		message = "pattern matched"
+	case EventCount:
+		message = fmt.Sprintf("count %g", e.Reading)
+	case EventLatency:
+		message = fmt.Sprintf("latency budget exceeded (%v)", e.Latency)
+	default:
+		message = string(e.Edge)
+	}
+	return title, fmt.Sprintf("%s: %s at %s", title, message, e.Time.Format("2006-01-02 15:04:05"))
+}
@@ -0,0 +1,273 @@
+package gpio
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An output whose automatic control is suspended.
+type Override struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+	// When control goes back to the automation. Zero if only by Resume.
+	Expires time.Time `json:"expires"`
+}
+
+// Lets people take manual control of outputs while commissioning or
+// troubleshooting: a forced output ignores writes from the automation
+// driving it until the override is resumed or expires, then returns to the
+// automation's latest value. Starting and ending an override is published
+// as EventOverride, labelled with the output's name.
+//
+// It is an http.Handler: GET lists the overrides as JSON, POST forces the
+// output given by the name form value to value, for the duration in for if
+// given (e.g. "30m"), and DELETE resumes the named output.
+type Overrides struct {
+	// Where overrides are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	outputs  map[string]*overridablePin
+	sequence uint64
+	// Events waiting for mu to be unlocked
+	outbox []Event
+}
+
+type overridablePin struct {
+	overrides *Overrides
+	name      string
+	pin       OutputPin
+
+	// Guarded by the overrides' mu
+	forced bool
+	value  int
+	// The automation's latest value, applied when the override ends
+	auto    int
+	hasAuto bool
+	expires time.Time
+	timer   *time.Timer
+}
+
+// Returns pin wrapped so that its writes, from the automation, can be
+// overridden by name.
+func (o *Overrides) Output(name string, pin OutputPin) OutputPin {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.outputs == nil {
+		o.outputs = map[string]*overridablePin{}
+	}
+	p := &overridablePin{overrides: o, name: name, pin: pin}
+	o.outputs[name] = p
+	return p
+}
+
+func (p *overridablePin) set(value int) error {
+	if value == 1 {
+		return p.pin.SetHigh()
+	}
+	return p.pin.SetLow()
+}
+
+// Forces the named output to value (0 or 1), for d or until Resume if d is
+// zero. Forcing an overridden output again replaces the override.
+func (o *Overrides) Force(name string, value int, d time.Duration) error {
+	o.mu.Lock()
+	defer o.unlock()
+
+	p, ok := o.outputs[name]
+	if !ok {
+		return fmt.Errorf("gpio: no output %q", name)
+	}
+	if value != 0 {
+		value = 1
+	}
+	if err := p.set(value); err != nil {
+		return err
+	}
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.expires = time.Time{}
+	if d > 0 {
+		p.expires = time.Now().Add(d)
+		var timer *time.Timer
+		timer = time.AfterFunc(d, func() {
+			o.mu.Lock()
+			defer o.unlock()
+
+			// Replaced or resumed since
+			if p.timer == timer {
+				o.resume(p)
+			}
+		})
+		p.timer = timer
+	}
+
+	if !p.forced {
+		p.forced = true
+		o.publish(name, 1)
+	}
+	p.value = value
+	return nil
+}
+
+// Ends the named output's override, setting it to the automation's latest
+// value, or leaving it as it is if the automation hasn't written it.
+func (o *Overrides) Resume(name string) error {
+	o.mu.Lock()
+	defer o.unlock()
+
+	p, ok := o.outputs[name]
+	if !ok || !p.forced {
+		return fmt.Errorf("gpio: output %q isn't overridden", name)
+	}
+	return o.resume(p)
+}
+
+// Called with mu held.
+func (o *Overrides) resume(p *overridablePin) error {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.forced = false
+	o.publish(p.name, 0)
+
+	if !p.hasAuto {
+		return nil
+	}
+	return p.set(p.auto)
+}
+
+// The outputs currently overridden, by name
+func (o *Overrides) Active() []Override {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var active []Override
+	for _, p := range o.outputs {
+		if p.forced {
+			active = append(active, Override{Name: p.name, Value: p.value, Expires: p.expires})
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Name < active[j].Name })
+	return active
+}
+
+// Queues an event for unlock. Called with mu held.
+func (o *Overrides) publish(name string, value int) {
+	o.sequence++
+	o.outbox = append(o.outbox, Event{Kind: EventOverride, Label: name, Value: value, Time: time.Now(), Sequence: o.sequence})
+}
+
+// Unlocks mu, then publishes the events queued while it was held, so a
+// subscriber that forces or resumes outputs itself can't deadlock.
+func (o *Overrides) unlock() {
+	events := o.outbox
+	o.outbox = nil
+	o.mu.Unlock()
+
+	bus := o.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	for _, e := range events {
+		bus.Publish(e)
+	}
+}
+
+// Records a write from the automation, applying it unless overridden.
+func (o *Overrides) write(p *overridablePin, value int, toggle bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if toggle {
+		value = p.auto ^ 1
+	}
+	if p.forced {
+		p.auto, p.hasAuto = value, true
+		return nil
+	}
+	if err := p.set(value); err != nil {
+		return err
+	}
+	p.auto, p.hasAuto = value, true
+	return nil
+}
+
+func (p *overridablePin) SetHigh() error {
+	return p.overrides.write(p, 1, false)
+}
+
+func (p *overridablePin) SetLow() error {
+	return p.overrides.write(p, 0, false)
+}
+
+func (p *overridablePin) Toggle() error {
+	return p.overrides.write(p, 0, true)
+}
+
+// Closes the pin, ending any override.
+func (p *overridablePin) Close() error {
+	o := p.overrides
+	o.mu.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if o.outputs[p.name] == p {
+		delete(o.outputs, p.name)
+	}
+	o.mu.Unlock()
+
+	return p.pin.Close()
+}
+
+func (o *Overrides) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(o.Active())
+		return
+
+	case http.MethodPost:
+		value, err := strconv.Atoi(r.FormValue("value"))
+		if err != nil || (value != 0 && value != 1) {
+			http.Error(w, "value must be 0 or 1", http.StatusBadRequest)
+			return
+		}
+		var d time.Duration
+		if s := r.FormValue("for"); s != "" {
+			if d, err = time.ParseDuration(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := o.Force(name, value, d); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+	case http.MethodDelete:
+		if err := o.Resume(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// Drives two outputs as a complementary pair, inverse always the opposite of
+// pin. Setting the pair switches the active side off, waits deadTime with both
+// low, then switches the other side on. Useful for H-bridge high/low sides,
+// where both conducting at once is a short circuit.
+//
+// Closing the pair closes both pins.
+func NewComplementaryPair(pin, inverse OutputPin, deadTime time.Duration) OutputPin {
+	return &complementaryPair{pin: pin, inverse: inverse, deadTime: deadTime}
+}
+
+type complementaryPair struct {
+	mu       sync.Mutex
+	pin      OutputPin
+	inverse  OutputPin
+	deadTime time.Duration
+	high     bool
+}
+
+func (c *complementaryPair) set(high bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.switchTo(high)
+}
+
+// Called with mu held.
+func (c *complementaryPair) switchTo(high bool) error {
+	on, off := c.pin, c.inverse
+	if !high {
+		on, off = off, on
+	}
+
+	if err := off.SetLow(); err != nil {
+		return err
+	}
+
+	time.Sleep(c.deadTime)
+
+	if err := on.SetHigh(); err != nil {
+		return err
+	}
+	c.high = high
+	return nil
+}
+
+func (c *complementaryPair) SetHigh() error {
+	return c.set(true)
+}
+
+func (c *complementaryPair) SetLow() error {
+	return c.set(false)
+}
+
+// A pair which hasn't been set yet is taken to be low, so goes high.
+func (c *complementaryPair) Toggle() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.switchTo(!c.high)
+}
+
+func (c *complementaryPair) Close() error {
+	err := c.pin.Close()
+	if err2 := c.inverse.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package gpio
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// One event in a Pattern. An Edge of "" or EdgeBoth matches either edge.
+type PatternStep struct {
+	Channel uint8
+	Edge    Edge
+}
+
+// Events on pins in a given order within a time window, e.g. beam A then beam
+// B within 100ms for someone passing one way, and B then A the other.
+type Pattern struct {
+	// Identifies the pattern in the events reported when it matches
+	Label string
+	Steps []PatternStep
+	// The time allowed from the first step to the last
+	Within time.Duration
+}
+
+// Recognises patterns in change events and reports each match as a single
+// EventPattern. Events which aren't the next step of a pattern are ignored
+// by it, so other activity doesn't break a match in progress.
+type PatternMatcher struct {
+	Patterns []Pattern
+	// Where matches are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	started  map[int][]patternAttempt
+	sequence uint64
+	// Events waiting for mu to be unlocked
+	outbox []Event
+}
+
+// A partly matched pattern
+type patternAttempt struct {
+	next  int
+	start time.Time
+}
+
+func (s PatternStep) match(e Event) bool {
+	return e.Channel == s.Channel && (s.Edge == "" || s.Edge == EdgeBoth || s.Edge == e.Edge)
+}
+
+// Feed the matcher an event. Events must be in time order.
+func (m *PatternMatcher) Feed(e Event) {
+	if e.Kind != EventChange {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.started == nil {
+		m.started = map[int][]patternAttempt{}
+	}
+
+	for i, p := range m.Patterns {
+		if len(p.Steps) == 0 {
+			continue
+		}
+
+		var attempts []patternAttempt
+		matched := false
+		for _, a := range m.started[i] {
+			if e.Time.Sub(a.start) > p.Within {
+				continue
+			}
+			if p.Steps[a.next].match(e) {
+				a.next++
+			}
+			if a.next == len(p.Steps) {
+				matched = true
+				break
+			}
+			attempts = append(attempts, a)
+		}
+
+		if matched {
+			// Anything else in progress overlapped this match, so is dropped
+			// rather than counted twice.
+			delete(m.started, i)
+			m.publish(p, e)
+			continue
+		}
+
+		if p.Steps[0].match(e) {
+			if len(p.Steps) == 1 {
+				m.publish(p, e)
+				continue
+			}
+			attempts = append(attempts, patternAttempt{next: 1, start: e.Time})
+		}
+		m.started[i] = attempts
+	}
+}
+
+// Queues a match for unlock. Called with mu held.
+func (m *PatternMatcher) publish(p Pattern, last Event) {
+	m.sequence++
+	m.outbox = append(m.outbox, Event{
+		Kind:     EventPattern,
+		Label:    p.Label,
+		Channel:  last.Channel,
+		Time:     last.Time,
+		Sequence: m.sequence,
+	})
+}
+
+// Unlocks mu, then publishes the matches queued while it was held, so a
+// subscriber feeding the matcher can't deadlock.
+func (m *PatternMatcher) unlock() {
+	events := m.outbox
+	m.outbox = nil
+	m.mu.Unlock()
+
+	bus := m.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	for _, e := range events {
+		bus.Publish(e)
+	}
+}
+
+// Feeds the matcher change events from bus (or DefaultBus if nil) until ctx
+// is cancelled.
+func (m *PatternMatcher) Run(ctx context.Context, bus *Bus) error {
+	if bus == nil {
+		bus = DefaultBus
+	}
+
+	// Every change on the bus comes here, so never hold up its publishers
+	sub := bus.SubscribeWithPolicy(Filter{Kinds: []EventKind{EventChange}}, PolicyDropOldest)
+	defer sub.Close()
+
+	for {
+		select {
+		case e := <-sub.Events():
+			m.Feed(e)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
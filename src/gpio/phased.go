@@ -0,0 +1,171 @@
+package gpio
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Software PWM on several outputs sharing one period, with each output's
+// pulse starting a fixed fraction of the period after the previous one's.
+// Spreading the turn-on points out this way avoids the current spike of many
+// LED channels switching on together.
+type PhasedPWM struct {
+	pins   []OutputPin
+	period time.Duration
+
+	mu      sync.Mutex
+	duty    []int
+	running bool
+	err     error
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// Creates PWM for the pins, with output i starting i/len(pins) of the way
+// through each period. All the outputs start at 0.
+func NewPhasedPWM(period time.Duration, pins ...OutputPin) (*PhasedPWM, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("gpio: invalid PWM period %v", period)
+	}
+	return &PhasedPWM{pins: pins, period: period, duty: make([]int, len(pins))}, nil
+}
+
+// Set the percentage of each period output i is high, from 0-100
+func (g *PhasedPWM) Set(i, value int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		return g.err
+	}
+	if i < 0 || i >= len(g.pins) {
+		return fmt.Errorf("gpio: no output %d in group of %d", i, len(g.pins))
+	}
+
+	g.duty[i] = value
+	if !g.running {
+		g.running = true
+		g.quit = make(chan struct{})
+		g.done = make(chan struct{})
+		go g.loop()
+	}
+	return nil
+}
+
+// The i'th output as a PWMPin. Closing it sets it to 0 but leaves the rest
+// of the group running.
+func (g *PhasedPWM) Pin(i int) PWMPin {
+	return phasedPWMPin{g, i}
+}
+
+type phasedPWMPin struct {
+	group *PhasedPWM
+	i     int
+}
+
+func (p phasedPWMPin) SetPWM(value int) error {
+	return p.group.Set(p.i, value)
+}
+
+func (p phasedPWMPin) Close() error {
+	return p.group.Set(p.i, 0)
+}
+
+type phasedTransition struct {
+	at    time.Duration
+	pin   OutputPin
+	value int
+}
+
+// The transitions in one period, in order.
+func (g *PhasedPWM) transitions() []phasedTransition {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var ts []phasedTransition
+	for i, pin := range g.pins {
+		start := g.period * time.Duration(i) / time.Duration(len(g.pins))
+		end := start + valueToDuration(g.duty[i], g.period)
+
+		switch {
+		case end == start:
+			ts = append(ts, phasedTransition{start, pin, 0})
+		case end-start >= g.period:
+			ts = append(ts, phasedTransition{start, pin, 1})
+		case end <= g.period:
+			ts = append(ts, phasedTransition{start, pin, 1}, phasedTransition{end, pin, 0})
+		default:
+			// The pulse runs over into the next period
+			ts = append(ts, phasedTransition{end - g.period, pin, 0}, phasedTransition{start, pin, 1})
+		}
+	}
+	sort.SliceStable(ts, func(i, j int) bool { return ts[i].at < ts[j].at })
+	return ts
+}
+
+func (g *PhasedPWM) cycle(start time.Time) error {
+	for _, t := range g.transitions() {
+		time.Sleep(time.Until(start.Add(t.at)))
+
+		var err error
+		if t.value == 1 {
+			err = t.pin.SetHigh()
+		} else {
+			err = t.pin.SetLow()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *PhasedPWM) loop() {
+	defer close(g.done)
+
+	ticker := time.NewTicker(g.period)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		if err := g.cycle(start); err != nil {
+			g.mu.Lock()
+			g.err = err
+			g.running = false
+			g.mu.Unlock()
+			return
+		}
+
+		select {
+		case start = <-ticker.C:
+		case <-g.quit:
+			return
+		}
+	}
+}
+
+// Stops the PWM, setting every output low and closing it.
+func (g *PhasedPWM) Close() error {
+	g.mu.Lock()
+	running := g.running
+	g.running = false
+	g.mu.Unlock()
+
+	if running {
+		close(g.quit)
+		<-g.done
+	}
+
+	var err error
+	for _, pin := range g.pins {
+		if err2 := pin.SetLow(); err == nil {
+			err = err2
+		}
+		if err2 := pin.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
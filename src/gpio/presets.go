@@ -0,0 +1,33 @@
+package gpio
+
+import "time"
+
+// Constructors for common peripherals with sensible defaults.
+
+// Opens a pin for reading a push button or switch to ground, with the
+// internal pull-up enabled where the backend supports it, unless overridden
+// with WithPull. It is debounced for 20ms unless overridden with
+// WithDebounce.
+func NewButton(channel uint8, options ...Option) (InputPin, error) {
+	return NewInputPin(channel, append([]Option{withDefaultPull(PullUp), WithDebounce(20 * time.Millisecond)}, options...)...)
+}
+
+// Opens a pin for driving a relay, switched off to start with. Most relay
+// boards are active-low, so the pin is too unless overridden with
+// WithActiveLow(false).
+func NewRelay(channel uint8, options ...Option) (OutputPin, error) {
+	return NewOutputPin(channel, append([]Option{WithActiveLow(true), WithInitialValue(0)}, options...)...)
+}
+
+// Opens a dimmable LED, switched off to start with.
+func NewLED(channel uint8, options ...Option) (PWMPin, error) {
+	pin, err := NewPWMPin(channel, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := pin.SetPWM(0); err != nil {
+		pin.Close()
+		return nil, err
+	}
+	return pin, nil
+}
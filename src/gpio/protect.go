@@ -0,0 +1,54 @@
+package gpio
+
+import (
+	"errors"
+)
+
+var ErrWriteProtected = errors.New("gpio: output is write protected")
+
+// The capability to change protected outputs. Keep it in the code which is
+// allowed to write, and hand the rest of the application the protected pin.
+type WriteToken struct {
+	// Not zero-sized, so every token has a distinct address
+	_ byte
+}
+
+func NewWriteToken() *WriteToken {
+	return &WriteToken{}
+}
+
+// Protects a safety-critical output from accidental writes by unrelated code.
+// The returned pin can be passed around freely, but SetHigh, SetLow and Close
+// fail with ErrWriteProtected; writing requires unlocking it with token.
+func Protect(pin OutputPin, token *WriteToken) *ProtectedOutputPin {
+	return &ProtectedOutputPin{pin: pin, token: token}
+}
+
+type ProtectedOutputPin struct {
+	pin   OutputPin
+	token *WriteToken
+}
+
+func (p *ProtectedOutputPin) SetHigh() error {
+	return ErrWriteProtected
+}
+
+func (p *ProtectedOutputPin) SetLow() error {
+	return ErrWriteProtected
+}
+
+func (p *ProtectedOutputPin) Toggle() error {
+	return ErrWriteProtected
+}
+
+func (p *ProtectedOutputPin) Close() error {
+	return ErrWriteProtected
+}
+
+// Returns the underlying pin, if token is the one the pin was protected with.
+func (p *ProtectedOutputPin) Unlock(token *WriteToken) (OutputPin, error) {
+	if token == nil || token != p.token {
+		return nil, ErrWriteProtected
+	}
+	return p.pin, nil
+}
@@ -0,0 +1,38 @@
+package gpio
+
+import (
+	"time"
+)
+
+// Sleeps are only trusted to wake up within this of their deadline; the rest
+// is spun out.
+const pulseSpin = 200 * time.Microsecond
+
+// Drives pin high for d, then low again, e.g. to trigger an ultrasonic
+// sensor or a camera shutter. Short pulses are timed by spinning rather than
+// sleeping, so they aren't stretched by the scheduler.
+func Pulse(pin OutputPin, d time.Duration) error {
+	if err := pin.SetHigh(); err != nil {
+		return err
+	}
+	waitUntil(time.Now().Add(d))
+	return pin.SetLow()
+}
+
+// Pulses pin in the background. The returned channel receives Pulse's result
+// once the pin is low again.
+func PulseAsync(pin OutputPin, d time.Duration) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- Pulse(pin, d)
+	}()
+	return done
+}
+
+func waitUntil(deadline time.Time) {
+	if d := time.Until(deadline) - pulseSpin; d > 0 {
+		time.Sleep(d)
+	}
+	for time.Now().Before(deadline) {
+	}
+}
@@ -0,0 +1,142 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Drives two outputs with complementary software PWM, for a half-bridge
+// driver. Each period the high side is on for the duty cycle and the low side
+// for the rest, with both off for deadTime around each transition so the
+// MOSFETs never conduct together. The dead-time comes out of the low side's
+// share, and the low side is skipped when there's no room left for it.
+//
+// Closing the pair switches both sides off and closes them. If a write
+// fails, both sides are switched off and the next SetPWM returns the error,
+// after which the pair can be started again.
+func NewComplementaryPWM(high, low OutputPin, period, deadTime time.Duration) (PWMPin, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("gpio: invalid PWM period %v", period)
+	}
+	if deadTime < 0 {
+		return nil, fmt.Errorf("gpio: invalid dead time %v", deadTime)
+	}
+	return &complementaryPWM{high: high, low: low, period: period, deadTime: deadTime}, nil
+}
+
+type complementaryPWM struct {
+	high, low OutputPin
+	period    time.Duration
+	deadTime  time.Duration
+
+	mu      sync.Mutex
+	duty    int
+	running bool
+	err     error
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// Set the percentage of each period the high side is on, from 0-100
+func (c *complementaryPWM) SetPWM(value int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.err; err != nil {
+		c.err = nil
+		return err
+	}
+
+	c.duty = value
+	if !c.running {
+		c.running = true
+		c.quit = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.loop()
+	}
+	return nil
+}
+
+func (c *complementaryPWM) cycle() error {
+	c.mu.Lock()
+	on := valueToDuration(c.duty, c.period)
+	c.mu.Unlock()
+	lowOn := c.period - on - 2*c.deadTime
+
+	if on > 0 {
+		if err := c.high.SetHigh(); err != nil {
+			return err
+		}
+		time.Sleep(on)
+		if err := c.high.SetLow(); err != nil {
+			return err
+		}
+	}
+
+	if lowOn > 0 {
+		time.Sleep(c.deadTime)
+		if err := c.low.SetHigh(); err != nil {
+			return err
+		}
+		time.Sleep(lowOn)
+		if err := c.low.SetLow(); err != nil {
+			return err
+		}
+		// Waited out here rather than left to the ticker, which fires at
+		// once after an overrunning cycle
+		time.Sleep(c.deadTime)
+	}
+	return nil
+}
+
+func (c *complementaryPWM) loop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.period)
+	defer ticker.Stop()
+
+	for {
+		if err := c.cycle(); err != nil {
+			// Either side may have been left on
+			c.high.SetLow()
+			c.low.SetLow()
+
+			c.mu.Lock()
+			c.err = err
+			c.running = false
+			c.mu.Unlock()
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+func (c *complementaryPWM) Close() error {
+	c.mu.Lock()
+	running := c.running
+	c.running = false
+	c.mu.Unlock()
+
+	if running {
+		close(c.quit)
+		<-c.done
+	}
+
+	err := c.high.SetLow()
+	if err2 := c.low.SetLow(); err == nil {
+		err = err2
+	}
+	if err2 := c.high.Close(); err == nil {
+		err = err2
+	}
+	if err2 := c.low.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
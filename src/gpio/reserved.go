@@ -0,0 +1,74 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Returned, wrapped in a PinError, for channels in ReservedChannels.
+var ErrReserved = errors.New("gpio: channel is reserved")
+
+// Channels which the package refuses to configure, mapped to the peripheral
+// using them. Reconfiguring these while the kernel drives the peripheral can
+// leave it unusable until reboot.
+//
+// When nil, the channels claimed by enabled peripherals in the device tree
+// are used (see DeviceTreeClaims), falling back to the I2C1, SPI0 and UART0
+// pins if the device tree can't be read. Set this before opening any pins to
+// use your own list, or to an empty map to disable the check.
+var ReservedChannels map[uint8]string
+
+// Used when the device tree is unavailable
+var fallbackReservedChannels = map[uint8]string{
+	2:  "i2c1",
+	3:  "i2c1",
+	7:  "spi0",
+	8:  "spi0",
+	9:  "spi0",
+	10: "spi0",
+	11: "spi0",
+	14: "uart0",
+	15: "uart0",
+}
+
+var defaultReserved struct {
+	sync.Once
+	channels map[uint8]string
+}
+
+func reservedChannels() map[uint8]string {
+	if ReservedChannels != nil {
+		return ReservedChannels
+	}
+	defaultReserved.Do(func() {
+		claims, err := DeviceTreeClaims()
+		if err != nil {
+			claims = fallbackReservedChannels
+		}
+		defaultReserved.channels = claims
+	})
+	return defaultReserved.channels
+}
+
+// Checks that a Raspberry Pi channel is free to be configured, so conflicts
+// with kernel peripherals are reported before anything is written. Pin
+// constructors call this for you with the Sysfs and MMap backends, whose
+// channels are the Pi's GPIO numbers.
+func ValidateChannel(channel uint8) error {
+	if owner, ok := reservedChannels()[channel]; ok {
+		return &PinError{Channel: channel, Op: "open", Err: fmt.Errorf("%w by %s", ErrReserved, owner)}
+	}
+	return nil
+}
+
+// Checks the channel if the pin's backend numbers channels as the Pi does.
+// Other backends, such as Chardev on another chip or a fake, number them
+// their own way.
+func (p *pin) validate() error {
+	switch p.backend.(type) {
+	case *sysfsBackend, *mmapBackend:
+		return ValidateChannel(p.channel)
+	}
+	return nil
+}
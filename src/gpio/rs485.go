@@ -0,0 +1,55 @@
+package gpio
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Serial ports which can wait for their transmit buffer to empty, such as
+// go.bug.st/serial's Port.
+type drainer interface {
+	Drain() error
+}
+
+// Wraps the port of a half-duplex RS-485 transceiver so that each write
+// enables the driver via de (the DE/RE direction pin), waits pre, writes,
+// waits post, and switches back to receive.
+//
+// If port has a Drain() error method it is called before the post delay, so
+// the line isn't released while bytes are still being shifted out. Otherwise
+// post must be long enough to cover transmission.
+func NewRS485Writer(port io.Writer, de OutputPin, pre, post time.Duration) io.Writer {
+	return &rs485Writer{port: port, de: de, pre: pre, post: post}
+}
+
+type rs485Writer struct {
+	mu   sync.Mutex
+	port io.Writer
+	de   OutputPin
+	pre  time.Duration
+	post time.Duration
+}
+
+func (w *rs485Writer) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.de.SetHigh(); err != nil {
+		return 0, err
+	}
+
+	time.Sleep(w.pre)
+
+	n, err := w.port.Write(b)
+	if d, ok := w.port.(drainer); ok && err == nil {
+		err = d.Drain()
+	}
+
+	time.Sleep(w.post)
+
+	if err2 := w.de.SetLow(); err == nil {
+		err = err2
+	}
+	return n, err
+}
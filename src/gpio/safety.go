@@ -0,0 +1,172 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrSafetyFault = errors.New("gpio: safety input faulted")
+
+// A redundant two-channel safety input, such as an emergency stop or guard
+// door switch with two contacts, monitored the way a safety relay does. It
+// is only safe while both channels say so. If they disagree for longer than
+// Window, or reading either fails, a fault is latched, published as an
+// EventFault, and stays until Reset even if the channels agree again.
+type SafetyInput struct {
+	Label string
+	// Each channel is safe when high
+	A, B InputPin
+	// B is wired to an opposite contact, so is safe when low
+	Antivalent bool
+	// How long the channels may disagree, as contacts never switch at quite
+	// the same moment
+	Window time.Duration
+	// How often Run reads the channels. 10ms when zero.
+	Interval time.Duration
+	// Where faults are published. DefaultBus when nil.
+	Bus *Bus
+
+	mu       sync.Mutex
+	safe     bool
+	faulted  bool
+	agreed   bool
+	since    time.Time
+	sequence uint64
+	// Events waiting for mu to be unlocked
+	outbox []Event
+}
+
+// Whether both channels are safe and there is no fault
+func (s *SafetyInput) Safe() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.safe && !s.faulted
+}
+
+// Whether a fault is latched
+func (s *SafetyInput) Faulted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.faulted
+}
+
+// Clears a latched fault. As with a safety relay, this fails with
+// ErrSafetyFault unless both channels agree.
+func (s *SafetyInput) Reset() error {
+	a, b, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	if a != b {
+		return ErrSafetyFault
+	}
+	s.safe, s.agreed = a, true
+	if s.faulted {
+		s.faulted = false
+		s.publish(0)
+	}
+	return nil
+}
+
+// Each channel's state, true when safe
+func (s *SafetyInput) read() (a, b bool, err error) {
+	a, err = s.A.IsHigh()
+	if err != nil {
+		return false, false, err
+	}
+	b, err = s.B.IsHigh()
+	if err != nil {
+		return false, false, err
+	}
+	return a, b != s.Antivalent, nil
+}
+
+// Reads the channels once, at now.
+func (s *SafetyInput) check(now time.Time) {
+	a, b, err := s.read()
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	switch {
+	case err != nil:
+		s.fault()
+	case a == b:
+		s.safe, s.agreed = a, true
+	default:
+		// Unsafe as soon as either channel is, whether or not it becomes
+		// a fault
+		s.safe = false
+		if s.agreed {
+			s.agreed = false
+			s.since = now
+		}
+		if now.Sub(s.since) > s.Window {
+			s.fault()
+		}
+	}
+}
+
+// Called with mu held.
+func (s *SafetyInput) fault() {
+	s.safe = false
+	if !s.faulted {
+		s.faulted = true
+		s.publish(1)
+	}
+}
+
+// Queues an event for unlock. Called with mu held.
+func (s *SafetyInput) publish(value int) {
+	s.sequence++
+	s.outbox = append(s.outbox, Event{Kind: EventFault, Label: s.Label, Value: value, Time: time.Now(), Sequence: s.sequence})
+}
+
+// Unlocks mu, then publishes the events queued while it was held, so a slow
+// subscriber can't hold up Safe and the checks.
+func (s *SafetyInput) unlock() {
+	events := s.outbox
+	s.outbox = nil
+	s.mu.Unlock()
+
+	bus := s.Bus
+	if bus == nil {
+		bus = DefaultBus
+	}
+	for _, e := range events {
+		bus.Publish(e)
+	}
+}
+
+// Monitors the channels until ctx is cancelled.
+func (s *SafetyInput) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+
+	s.mu.Lock()
+	s.agreed = true
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.check(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			s.check(now)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package gpio_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gpio"
+	"gpio/gpiotest"
+)
+
+// Waits up to a second for cond to hold.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSafetyInputDiscrepancy(t *testing.T) {
+	tests := []struct {
+		name       string
+		antivalent bool
+		// The channels' levels to start with, then after the change
+		a, b     int
+		toA, toB int
+		// How long B lags behind A
+		lag        time.Duration
+		wantSafe   bool
+		wantFaults bool
+	}{
+		{name: "both safe", a: 1, b: 1, toA: 1, toB: 1, wantSafe: true},
+		{name: "both opened together", a: 1, b: 1, toA: 0, toB: 0},
+		{name: "opened within window", a: 1, b: 1, toA: 0, toB: 0, lag: 5 * time.Millisecond},
+		{name: "one channel stuck", a: 1, b: 1, toA: 0, toB: 1, wantFaults: true},
+		{name: "antivalent safe", antivalent: true, a: 1, b: 0, toA: 1, toB: 0, wantSafe: true},
+		{name: "antivalent stuck", antivalent: true, a: 1, b: 0, toA: 0, toB: 0, wantFaults: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := gpiotest.NewInputPin(tt.a), gpiotest.NewInputPin(tt.b)
+			bus := gpio.NewBus()
+			faults := bus.Subscribe(gpio.Filter{Kinds: []gpio.EventKind{gpio.EventFault}})
+			defer faults.Close()
+
+			s := &gpio.SafetyInput{
+				Label:      "estop",
+				A:          a,
+				B:          b,
+				Antivalent: tt.antivalent,
+				Window:     50 * time.Millisecond,
+				Interval:   time.Millisecond,
+				Bus:        bus,
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go s.Run(ctx)
+
+			eventually(t, "safe at start", s.Safe)
+
+			a.Set(tt.toA)
+			time.Sleep(tt.lag)
+			b.Set(tt.toB)
+
+			if tt.wantFaults {
+				eventually(t, "fault", s.Faulted)
+				e := <-faults.Events()
+				if e.Label != "estop" || e.Value != 1 {
+					t.Errorf("got event %+v, want a fault raised", e)
+				}
+
+				// Latched even once the channels agree again, until Reset
+				a.Set(tt.a)
+				b.Set(tt.b)
+				time.Sleep(10 * time.Millisecond)
+				if !s.Faulted() {
+					t.Fatal("fault cleared without Reset")
+				}
+				if err := s.Reset(); err != nil {
+					t.Fatalf("Reset: %v", err)
+				}
+				if s.Faulted() {
+					t.Error("still faulted after Reset")
+				}
+				return
+			}
+
+			// Well past the window
+			time.Sleep(100 * time.Millisecond)
+			if s.Faulted() {
+				t.Fatal("faulted")
+			}
+			if got := s.Safe(); got != tt.wantSafe {
+				t.Errorf("Safe() = %v, want %v", got, tt.wantSafe)
+			}
+		})
+	}
+}
+
+func TestSafetyInputResetRefusedWhileChannelsDisagree(t *testing.T) {
+	a, b := gpiotest.NewInputPin(1), gpiotest.NewInputPin(1)
+	s := &gpio.SafetyInput{A: a, B: b, Window: 10 * time.Millisecond, Interval: time.Millisecond, Bus: gpio.NewBus()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	a.Set(0)
+	eventually(t, "fault", s.Faulted)
+
+	if err := s.Reset(); !errors.Is(err, gpio.ErrSafetyFault) {
+		t.Fatalf("Reset() = %v, want ErrSafetyFault", err)
+	}
+	if !s.Faulted() {
+		t.Error("fault cleared")
+	}
+}
@@ -0,0 +1,235 @@
+package gpio
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Services many software PWM outputs from one timing loop, instead of a
+// goroutine and ticker each, for driving lots of LEDs. Every output goes high
+// at the start of each period and low as its duty cycle runs out.
+//
+// Pins from NewPWMPin use a scheduler with WithPWMScheduler; other outputs
+// can be added with Add.
+type PWMScheduler struct {
+	period time.Duration
+
+	mu      sync.Mutex
+	outputs map[OutputPin]*scheduledOutput
+	running bool
+	quit    chan struct{}
+	done    chan struct{}
+
+	// Held while a cycle is writing, so remove can wait for it
+	cycleMu sync.Mutex
+}
+
+type scheduledOutput struct {
+	ppm int
+	// Told if a write fails, after which the output is dropped
+	fail func(error)
+}
+
+func NewPWMScheduler(period time.Duration) (*PWMScheduler, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("gpio: invalid PWM period %v", period)
+	}
+	return &PWMScheduler{period: period, outputs: map[OutputPin]*scheduledOutput{}}, nil
+}
+
+// Schedules pin at the duty cycle in parts per million, replacing any
+// previous duty cycle for it.
+func (s *PWMScheduler) set(pin OutputPin, ppm int, fail func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.outputs[pin] = &scheduledOutput{ppm: ppm, fail: fail}
+	if !s.running {
+		s.running = true
+		s.quit = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.loop(s.quit, s.done)
+	}
+}
+
+// Stops scheduling pin, leaving it as the last cycle left it. Waits for a
+// cycle in progress, so nothing writes the pin once this returns.
+func (s *PWMScheduler) remove(pin OutputPin) {
+	s.mu.Lock()
+	delete(s.outputs, pin)
+	s.mu.Unlock()
+
+	s.cycleMu.Lock()
+	s.cycleMu.Unlock()
+}
+
+type scheduledWrite struct {
+	at  time.Duration
+	pin OutputPin
+	out *scheduledOutput
+}
+
+// Runs one period, returning the failed outputs' callbacks for the caller to
+// call once the cycle is over, as they may be waiting in remove.
+func (s *PWMScheduler) cycle(start time.Time) []func() {
+	s.cycleMu.Lock()
+	defer s.cycleMu.Unlock()
+
+	s.mu.Lock()
+	writes := make([]scheduledWrite, 0, len(s.outputs))
+	for pin, out := range s.outputs {
+		writes = append(writes, scheduledWrite{s.period * time.Duration(out.ppm) / 1e6, pin, out})
+	}
+	s.mu.Unlock()
+	sort.Slice(writes, func(i, j int) bool { return writes[i].at < writes[j].at })
+
+	var callbacks []func()
+	failed := map[OutputPin]bool{}
+	fail := func(w scheduledWrite, err error) {
+		failed[w.pin] = true
+		s.mu.Lock()
+		// A write racing with the output being removed or closed isn't a
+		// failure of the schedule
+		current := s.outputs[w.pin] == w.out
+		if current {
+			delete(s.outputs, w.pin)
+		}
+		s.mu.Unlock()
+		if current && w.out.fail != nil {
+			callbacks = append(callbacks, func() { w.out.fail(err) })
+		}
+	}
+
+	for _, w := range writes {
+		if err := w.pin.SetHigh(); err != nil {
+			fail(w, err)
+		}
+	}
+	for _, w := range writes {
+		if failed[w.pin] {
+			continue
+		}
+		time.Sleep(time.Until(start.Add(w.at)))
+		if err := w.pin.SetLow(); err != nil {
+			fail(w, err)
+		}
+	}
+	return callbacks
+}
+
+// Runs cycles until quit is closed, or there is nothing left to schedule.
+func (s *PWMScheduler) loop(quit, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		for _, callback := range s.cycle(start) {
+			callback()
+		}
+
+		s.mu.Lock()
+		idle := len(s.outputs) == 0 && s.quit == quit
+		if idle {
+			s.running = false
+		}
+		s.mu.Unlock()
+		if idle {
+			return
+		}
+
+		select {
+		case start = <-ticker.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Schedules pin, returning it as a PWMPin. Closing that stops scheduling the
+// pin, sets it low and closes it.
+func (s *PWMScheduler) Add(pin OutputPin) PWMPin {
+	return &scheduledPWMPin{scheduler: s, pin: pin}
+}
+
+type scheduledPWMPin struct {
+	scheduler *PWMScheduler
+	pin       OutputPin
+
+	mu sync.Mutex
+	// Why the scheduler dropped the pin, until the next SetPWM reports it
+	err error
+}
+
+// Set the percentage of each period the output is high, from 0-100
+func (p *scheduledPWMPin) SetPWM(value int) error {
+	return p.setDuty(value * 1e4)
+}
+
+// Set the fraction of each period the output is high, from 0.0-1.0
+func (p *scheduledPWMPin) SetDuty(duty float64) error {
+	return p.setDuty(int(duty*1e6 + 0.5))
+}
+
+// Reports a write failure since the last call, leaving the pin unscheduled,
+// as the pin's own scheduled PWM does.
+func (p *scheduledPWMPin) setDuty(ppm int) error {
+	p.mu.Lock()
+	err := p.err
+	p.err = nil
+	p.mu.Unlock()
+
+	switch {
+	case ppm <= 0:
+		p.scheduler.remove(p.pin)
+		if err != nil {
+			return err
+		}
+		return p.pin.SetLow()
+	case ppm >= 1e6:
+		p.scheduler.remove(p.pin)
+		if err != nil {
+			return err
+		}
+		return p.pin.SetHigh()
+	}
+	if err != nil {
+		return err
+	}
+	p.scheduler.set(p.pin, ppm, p.failed)
+	return nil
+}
+
+// Told by the scheduler when a write fails and it drops the pin.
+func (p *scheduledPWMPin) failed(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
+
+func (p *scheduledPWMPin) Close() error {
+	p.scheduler.remove(p.pin)
+	err := p.pin.SetLow()
+	if err2 := p.pin.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Stops the loop. Outputs are left as they are, and aren't closed.
+func (s *PWMScheduler) Close() error {
+	s.mu.Lock()
+	running, quit, done := s.running, s.quit, s.done
+	s.running = false
+	s.mu.Unlock()
+
+	if running {
+		close(quit)
+		<-done
+	}
+	return nil
+}
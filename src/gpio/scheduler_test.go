@@ -0,0 +1,103 @@
+package gpio_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"gpio"
+	"gpio/gpiotest"
+)
+
+func TestPWMSchedulerDuty(t *testing.T) {
+	const period = 20 * time.Millisecond
+
+	s, err := gpio.NewPWMScheduler(period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Scheduled together, so they share cycles
+	duties := []int{10, 25, 50, 75, 90}
+	fakes := make([]*gpiotest.OutputPin, len(duties))
+	pins := make([]gpio.PWMPin, len(duties))
+	for i, duty := range duties {
+		fakes[i] = gpiotest.NewOutputPin()
+		pins[i] = s.Add(fakes[i])
+		if err := pins[i].SetPWM(duty); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(10 * period)
+	for _, p := range pins {
+		p.Close()
+	}
+
+	for i, duty := range duties {
+		want := period * time.Duration(duty) / 100
+		var high []time.Duration
+		writes := fakes[i].Writes()
+		for j := 1; j < len(writes); j++ {
+			if writes[j-1].Value == 1 && writes[j].Value == 0 {
+				high = append(high, writes[j].Time.Sub(writes[j-1].Time))
+			}
+		}
+		if len(high) < 5 {
+			t.Errorf("%d%%: %d pulses in 10 periods", duty, len(high))
+			continue
+		}
+
+		// The median pulse, as one slow cycle shouldn't fail the test
+		sort.Slice(high, func(i, j int) bool { return high[i] < high[j] })
+		median := high[len(high)/2]
+		if diff := median - want; diff < -2*time.Millisecond || diff > 2*time.Millisecond {
+			t.Errorf("%d%%: high for %v, want %v", duty, median, want)
+		}
+	}
+}
+
+func TestPWMSchedulerFullAndOff(t *testing.T) {
+	s, err := gpio.NewPWMScheduler(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	tests := []struct {
+		duty int
+		want int
+	}{
+		{duty: 0, want: 0},
+		{duty: 100, want: 1},
+	}
+	for _, tt := range tests {
+		fake := gpiotest.NewOutputPin()
+		p := s.Add(fake)
+		if err := p.SetPWM(50); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if err := p.SetPWM(tt.duty); err != nil {
+			t.Fatal(err)
+		}
+		n := len(fake.Writes())
+
+		// Nothing writes the pin once it is unscheduled
+		time.Sleep(30 * time.Millisecond)
+		if got := fake.Value(); got != tt.want {
+			t.Errorf("%d%%: left at %d, want %d", tt.duty, got, tt.want)
+		}
+		if got := len(fake.Writes()); got != n {
+			t.Errorf("%d%%: %d writes after unscheduling", tt.duty, got-n)
+		}
+	}
+}
+
+func TestNewPWMSchedulerInvalidPeriod(t *testing.T) {
+	for _, period := range []time.Duration{0, -time.Millisecond} {
+		if _, err := gpio.NewPWMScheduler(period); err == nil {
+			t.Errorf("period %v accepted", period)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package gpio
+
+import (
+	"context"
+	"time"
+)
+
+// One action in a Sequence.
+type Step struct {
+	Pin  OutputPin
+	High bool
+	// How long to wait after this step before the next
+	Delay time.Duration
+}
+
+// An ordered list of output changes, e.g. for powering up multi-rail
+// hardware where the order and spacing matter.
+type Sequence []Step
+
+// Runs the steps in order. If ctx is cancelled the sequence stops before the
+// next step and returns ctx.Err(), leaving the outputs as they are. A failing
+// step also stops the sequence.
+func (s Sequence) Run(ctx context.Context) error {
+	for _, step := range s {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var err error
+		if step.High {
+			err = step.Pin.SetHigh()
+		} else {
+			err = step.Pin.SetLow()
+		}
+		if err != nil {
+			return err
+		}
+
+		if step.Delay > 0 {
+			timer := time.NewTimer(step.Delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package gpio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// A hobby servo on a PWM pin, positioned by angle. Servos expect a pulse
+// every 20ms, by default 1ms wide for 0° and 2ms for 180°. Cheap servos
+// differ a lot, so calibrate each with ServoOptions.
+//
+// Pins which take fractional duty cycles, such as NewHardwarePWMPin's or
+// NewPWMPin's, give the smoothest positioning. Through SetPWM alone the
+// position can only move in steps of about 36°.
+type Servo struct {
+	pin PWMPin
+
+	minPulse, maxPulse time.Duration
+	trim               time.Duration
+	rotation           float64
+
+	mu    sync.Mutex
+	angle float64
+}
+
+const servoPeriod = 20 * time.Millisecond
+
+// Calibrates a Servo.
+type ServoOption func(*Servo)
+
+// Set the pulse widths at either end of the servo's travel. Pulses are never
+// sent outside them, so find the servo's real limits before widening them:
+// driving past its end stops strips gears.
+func WithPulseRange(min, max time.Duration) ServoOption {
+	return func(s *Servo) {
+		s.minPulse, s.maxPulse = min, max
+	}
+}
+
+// Shift every pulse by trim, e.g. so 90° is straight ahead. Pulses are
+// still kept within the pulse range.
+func WithCenterTrim(trim time.Duration) ServoOption {
+	return func(s *Servo) {
+		s.trim = trim
+	}
+}
+
+// Set the angle the pulse range covers, e.g. 270 for a 270° servo.
+func WithRotationRange(deg float64) ServoOption {
+	return func(s *Servo) {
+		if deg > 0 {
+			s.rotation = deg
+		}
+	}
+}
+
+// Drives a servo from pin, setting it to 50Hz where the pin can change
+// frequency. Pins which can't, such as those on a PWMScheduler, are refused
+// rather than sending pulses sized for the wrong period. The servo isn't
+// moved until the first SetAngle.
+func NewServo(pin PWMPin, options ...ServoOption) (*Servo, error) {
+	s := &Servo{
+		pin:      pin,
+		minPulse: time.Millisecond,
+		maxPulse: 2 * time.Millisecond,
+		rotation: 180,
+		angle:    math.NaN(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.minPulse <= 0 || s.maxPulse <= s.minPulse || s.maxPulse > servoPeriod {
+		return nil, fmt.Errorf("gpio: invalid servo pulse range %v-%v", s.minPulse, s.maxPulse)
+	}
+
+	if f, ok := pin.(PWMFrequencySetter); ok {
+		if err := f.SetPWMFrequency(float64(time.Second / servoPeriod)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Moves the servo to deg, from 0 to the rotation range (180 by default).
+// Angles outside that are clamped.
+func (s *Servo) SetAngle(deg float64) error {
+	deg = math.Max(0, math.Min(s.rotation, deg))
+	pulse := s.minPulse + time.Duration(deg/s.rotation*float64(s.maxPulse-s.minPulse)) + s.trim
+	if pulse < s.minPulse {
+		pulse = s.minPulse
+	} else if pulse > s.maxPulse {
+		pulse = s.maxPulse
+	}
+	duty := float64(pulse) / float64(servoPeriod)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := setDutyFraction(s.pin, duty); err != nil {
+		return err
+	}
+	s.angle = deg
+	return nil
+}
+
+// The angle last set, or NaN before the first SetAngle
+func (s *Servo) Angle() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.angle
+}
+
+// Stops the pulses, so the servo goes limp, and closes the pin.
+func (s *Servo) Close() error {
+	return s.pin.Close()
+}
@@ -0,0 +1,118 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Drives an output with first-order sigma-delta modulation instead of PWM.
+// Every tick the output is set high or low so that the running average
+// tracks the duty cycle, spreading the high ticks as evenly as possible
+// rather than bunching them into one pulse per period. Through an RC filter
+// this makes a smoother pseudo-DAC than PWM at the same tick rate, and LEDs
+// flicker less when dimmed.
+//
+// Only transitions are written, so a steady 0 or 100 costs nothing but the
+// ticks. Closing the pin sets the output low and closes it.
+func NewSigmaDeltaPin(pin OutputPin, tick time.Duration) (PWMPin, error) {
+	if tick <= 0 {
+		return nil, fmt.Errorf("gpio: invalid sigma-delta tick %v", tick)
+	}
+	return &sigmaDeltaPin{pin: pin, tick: tick}, nil
+}
+
+type sigmaDeltaPin struct {
+	pin  OutputPin
+	tick time.Duration
+
+	mu      sync.Mutex
+	duty    int
+	running bool
+	err     error
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// Set the percentage of ticks the output is high, from 0-100
+func (s *sigmaDeltaPin) SetPWM(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.duty = value
+	if !s.running {
+		s.running = true
+		s.quit = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.loop()
+	}
+	return nil
+}
+
+func (s *sigmaDeltaPin) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	var acc int
+	last := -1
+	for {
+		s.mu.Lock()
+		duty := s.duty
+		s.mu.Unlock()
+
+		// Output high whenever the accumulated duty reaches a whole tick
+		value := 0
+		acc += duty
+		if acc >= 100 {
+			acc -= 100
+			value = 1
+		}
+
+		if value != last {
+			var err error
+			if value == 1 {
+				err = s.pin.SetHigh()
+			} else {
+				err = s.pin.SetLow()
+			}
+			if err != nil {
+				s.mu.Lock()
+				s.err = err
+				s.running = false
+				s.mu.Unlock()
+				return
+			}
+			last = value
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *sigmaDeltaPin) Close() error {
+	s.mu.Lock()
+	running := s.running
+	s.running = false
+	s.mu.Unlock()
+
+	if running {
+		close(s.quit)
+		<-s.done
+	}
+
+	err := s.pin.SetLow()
+	if err2 := s.pin.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Time-proportioning control of an output over a long period, from seconds to
+// minutes. Each period the output is high for the duty percentage of it, then
+// low for the rest; changes take effect at the start of the next period. Meant
+// for heaters on relays or SSRs, where normal PWM frequencies would wear out
+// the relay or never let it switch at all.
+//
+// The output is switched by timers rather than a software PWM loop, so it
+// costs nothing between transitions. Closing the pin sets the output low and
+// closes it. If a write fails, switching stops and the next SetPWM returns
+// the error, after which it can be started again.
+func NewTimeProportioningPin(pin OutputPin, period time.Duration) (PWMPin, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("gpio: invalid time-proportioning period %v", period)
+	}
+	return &timeProportioningPin{pin: pin, period: period}, nil
+}
+
+type timeProportioningPin struct {
+	mu      sync.Mutex
+	pin     OutputPin
+	period  time.Duration
+	duty    int
+	running bool
+	err     error
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// Set the percentage of each period the output is high, from 0-100
+func (t *timeProportioningPin) SetPWM(value int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.err; err != nil {
+		t.err = nil
+		return err
+	}
+
+	t.duty = value
+	if !t.running {
+		t.running = true
+		t.quit = make(chan struct{})
+		t.done = make(chan struct{})
+		go t.loop()
+	}
+	return nil
+}
+
+// Waits for d, returning false if the pin is closed meanwhile.
+func (t *timeProportioningPin) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-t.quit:
+		return false
+	}
+}
+
+func (t *timeProportioningPin) loop() {
+	defer close(t.done)
+
+	for {
+		t.mu.Lock()
+		high := valueToDuration(t.duty, t.period)
+		t.mu.Unlock()
+
+		var err error
+		rest := t.period
+		if high > 0 {
+			err = t.pin.SetHigh()
+		}
+		if err == nil && high < t.period {
+			if !t.wait(high) {
+				return
+			}
+			err = t.pin.SetLow()
+			rest = t.period - high
+		}
+		if err != nil {
+			t.mu.Lock()
+			t.err = err
+			t.running = false
+			t.mu.Unlock()
+			return
+		}
+
+		if !t.wait(rest) {
+			return
+		}
+	}
+}
+
+func (t *timeProportioningPin) Close() error {
+	t.mu.Lock()
+	running := t.running
+	t.running = false
+	t.mu.Unlock()
+
+	if running {
+		close(t.quit)
+		<-t.done
+	}
+
+	err := t.pin.SetLow()
+	if err2 := t.pin.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
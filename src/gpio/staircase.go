@@ -0,0 +1,141 @@
+package gpio
+
+import (
+	"context"
+	"time"
+)
+
+// A motion sensor on a staircase, and the step it is at.
+type StairSensor struct {
+	Channel uint8
+	Step    int
+}
+
+// Lights a staircase step by step from wherever motion is seen: each step
+// fades on StepDelay after the one before it, spreading out from the
+// sensor's step, and once no motion has been seen for OnTime they fade off
+// in the same order, following whoever is on the stairs.
+type StaircaseLights struct {
+	// The steps' dimmed lights, from the bottom
+	Steps   []PWMPin
+	Sensors []StairSensor
+	// The edge when a sensor sees motion. EdgeRising when empty.
+	Edge      Edge
+	StepDelay time.Duration
+	// How long each step takes to fade on or off
+	FadeTime time.Duration
+	OnTime   time.Duration
+	// The lit level, from 0-100. 100 when zero.
+	Brightness int
+}
+
+// Fading the steps towards a level, starting from each step's level when
+// the fade began.
+type stairFade struct {
+	start  time.Time
+	origin int
+	from   []int
+	to     int
+}
+
+// The level of step i at now
+func (l *StaircaseLights) level(f stairFade, i int, now time.Time) int {
+	distance := i - f.origin
+	if distance < 0 {
+		distance = -distance
+	}
+
+	elapsed := now.Sub(f.start) - time.Duration(distance)*l.StepDelay
+	switch {
+	case elapsed <= 0:
+		return f.from[i]
+	case elapsed >= l.FadeTime:
+		return f.to
+	}
+	return f.from[i] + int(float64(f.to-f.from[i])*float64(elapsed)/float64(l.FadeTime))
+}
+
+// Lights the steps for motion events from bus (or DefaultBus if nil) until
+// ctx is cancelled, or setting a step fails. The steps are left off.
+func (l *StaircaseLights) Run(ctx context.Context, bus *Bus) error {
+	if bus == nil {
+		bus = DefaultBus
+	}
+	edge := l.Edge
+	if edge == "" {
+		edge = EdgeRising
+	}
+	brightness := l.Brightness
+	if brightness <= 0 || brightness > 100 {
+		brightness = 100
+	}
+
+	steps := map[uint8]int{}
+	var channels []uint8
+	for _, s := range l.Sensors {
+		steps[s.Channel] = s.Step
+		channels = append(channels, s.Channel)
+	}
+	// Only the latest motion on each sensor matters
+	sub := bus.SubscribeWithPolicy(Filter{Kinds: []EventKind{EventChange}, Channels: channels, Edge: edge}, PolicyCoalesce)
+	defer sub.Close()
+
+	levels := make([]int, len(l.Steps))
+	set := func(i, level int) error {
+		if level == levels[i] {
+			return nil
+		}
+		if err := l.Steps[i].SetPWM(level); err != nil {
+			return err
+		}
+		levels[i] = level
+		return nil
+	}
+	fade := func(origin, to int, now time.Time) stairFade {
+		return stairFade{start: now, origin: origin, from: append([]int(nil), levels...), to: to}
+	}
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	var (
+		current stairFade
+		fading  bool
+		lit     bool
+		offAt   time.Time
+	)
+	for {
+		select {
+		case e := <-sub.Events():
+			if !lit {
+				current, fading, lit = fade(steps[e.Channel], brightness, time.Now()), true, true
+			}
+			offAt = time.Now().Add(l.OnTime)
+
+		case now := <-ticker.C:
+			if lit && !now.Before(offAt) {
+				current, fading, lit = fade(current.origin, 0, now), true, false
+			}
+			if !fading {
+				continue
+			}
+			done := true
+			for i := range l.Steps {
+				level := l.level(current, i, now)
+				if err := set(i, level); err != nil {
+					return err
+				}
+				done = done && level == current.to
+			}
+			fading = !done
+
+		case <-ctx.Done():
+			for i := range l.Steps {
+				if err := set(i, 0); err != nil {
+					return err
+				}
+			}
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// The sun never rises or never sets on the day, near the poles.
+var ErrNoSunEvent = errors.New("gpio: the sun doesn't rise or set that day")
+
+// The sunrise and sunset on date's calendar day at a latitude and longitude
+// (degrees, north and east positive), accurate to a minute or so. Times are
+// in date's location.
+func SunTimes(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, err error) {
+	const rad = math.Pi / 180
+
+	// Days from 2000-01-01, the J2000 epoch
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	n := math.Round(day.Sub(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+
+	// The sunrise equation, as used by NOAA
+	mean := n - longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*mean, 360)
+	center := 1.9148*math.Sin(anomaly*rad) + 0.02*math.Sin(2*anomaly*rad) + 0.0003*math.Sin(3*anomaly*rad)
+	ecliptic := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := 2451545 + mean + 0.0053*math.Sin(anomaly*rad) - 0.0069*math.Sin(2*ecliptic*rad)
+	declination := math.Asin(math.Sin(ecliptic*rad) * math.Sin(23.4397*rad))
+
+	// -0.833° allows for refraction and the sun's disc
+	cosHour := (math.Sin(-0.833*rad) - math.Sin(latitude*rad)*math.Sin(declination)) /
+		(math.Cos(latitude*rad) * math.Cos(declination))
+	if cosHour < -1 || cosHour > 1 {
+		return time.Time{}, time.Time{}, ErrNoSunEvent
+	}
+	hour := math.Acos(cosHour) / rad
+
+	julian := func(jd float64) time.Time {
+		return time.Unix(0, int64((jd-2440587.5)*86400*1e9)).In(date.Location())
+	}
+	return julian(transit - hour/360), julian(transit + hour/360), nil
+}
+
+// A time relative to sunrise or sunset at a place, e.g. to close a coop
+// door 20 minutes after sunset or turn on outdoor lights before it.
+type SunTrigger struct {
+	// Degrees, north and east positive
+	Latitude, Longitude float64
+	// Sunset rather than sunrise
+	Sunset bool
+	// Added to the sunrise or sunset, so negative is before it
+	Offset time.Duration
+}
+
+// The first time the trigger fires after after, skipping days the sun
+// doesn't rise or set. Days are in after's location.
+func (t SunTrigger) Next(after time.Time) (time.Time, error) {
+	// From the day before, in case the offset pushes an event past midnight
+	for i := -1; i <= 366; i++ {
+		sunrise, sunset, err := SunTimes(after.AddDate(0, 0, i), t.Latitude, t.Longitude)
+		if err == ErrNoSunEvent {
+			continue
+		}
+
+		at := sunrise
+		if t.Sunset {
+			at = sunset
+		}
+		if at = at.Add(t.Offset); at.After(after) {
+			return at, nil
+		}
+	}
+	return time.Time{}, ErrNoSunEvent
+}
+
+// Blocks until the trigger next fires, returning the time it was due, or
+// ctx's error if it is cancelled first.
+func (t SunTrigger) Wait(ctx context.Context) (time.Time, error) {
+	at, err := t.Next(time.Now())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return at, nil
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	}
+}
@@ -0,0 +1,241 @@
+package gpio
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The /sys/class/gpio interface. Deprecated in newer kernels, but available
+// everywhere. This is the default backend.
+var Sysfs Backend = &sysfsBackend{
+	values:  map[uint8]*os.File{},
+	watches: map[uint8]*edgeWatch{},
+}
+
+type sysfsBackend struct {
+	mu      sync.Mutex
+	values  map[uint8]*os.File
+	watches map[uint8]*edgeWatch
+}
+
+func writeSysfsFile(path, value string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 200)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(value)
+	return err
+}
+
+// Reads a value file from the start, as sysfs regenerates it on each read.
+// Reading at an offset rather than seeking lets several goroutines read the
+// file at once.
+func readSysfsValue(file *os.File) (int, error) {
+	b := make([]byte, 16)
+	n, err := file.ReadAt(b, 0)
+	if err != nil && !(err == io.EOF && n > 0) {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(b[:n])))
+}
+
+func (s *sysfsBackend) Export(channel uint8) error {
+	// if this exists we have to unexport it first
+	_, err := os.Stat(fmt.Sprintf("/sys/class/gpio/gpio%d", channel))
+	if err == nil {
+		if err = s.Unexport(channel); err != nil {
+			return err
+		}
+	} else {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err = writeSysfsFile("/sys/class/gpio/export", fmt.Sprintf("%d", channel)); err != nil {
+		return err
+	}
+
+	valueFile, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/value", channel), os.O_RDWR, 600)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.values[channel] = valueFile
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Takes over a channel exported by an earlier process as it is, rather than
+// unexporting it first, so outputs keep driving their current value.
+func (s *sysfsBackend) Adopt(channel uint8) (Direction, error) {
+	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", channel)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := s.Export(channel); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	} else {
+		valueFile, err := os.OpenFile(dir+"/value", os.O_RDWR, 600)
+		if err != nil {
+			return "", err
+		}
+		s.mu.Lock()
+		s.values[channel] = valueFile
+		s.mu.Unlock()
+	}
+
+	b, err := ioutil.ReadFile(dir + "/direction")
+	if err != nil {
+		return "", err
+	}
+	return Direction(strings.TrimSpace(string(b))), nil
+}
+
+// Closes the channel's files, leaving it exported.
+func (s *sysfsBackend) Release(channel uint8) error {
+	s.mu.Lock()
+	valueFile := s.values[channel]
+	delete(s.values, channel)
+	s.mu.Unlock()
+
+	if valueFile == nil {
+		return nil
+	}
+	return valueFile.Close()
+}
+
+func (s *sysfsBackend) Unexport(channel uint8) error {
+	if err := s.Release(channel); err != nil {
+		return err
+	}
+
+	return writeSysfsFile("/sys/class/gpio/unexport", fmt.Sprintf("%d", channel))
+}
+
+func (s *sysfsBackend) SetDirection(channel uint8, direction Direction) error {
+	return writeSysfsFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", channel), string(direction))
+}
+
+// Writing "high" or "low" to the direction file sets the value along with
+// the direction, in one step.
+func (s *sysfsBackend) SetOutput(channel uint8, value int) error {
+	direction := "low"
+	if value != 0 {
+		direction = "high"
+	}
+	return writeSysfsFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", channel), direction)
+}
+
+func (s *sysfsBackend) valueFile(channel uint8) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	valueFile, ok := s.values[channel]
+	if !ok {
+		return nil, ErrNotExported
+	}
+	return valueFile, nil
+}
+
+func (s *sysfsBackend) Read(channel uint8) (int, error) {
+	valueFile, err := s.valueFile(channel)
+	if err != nil {
+		return 0, err
+	}
+
+	return readSysfsValue(valueFile)
+}
+
+func (s *sysfsBackend) Write(channel uint8, value int) error {
+	valueFile, err := s.valueFile(channel)
+	if err != nil {
+		return err
+	}
+
+	if value == 0 {
+		_, err = valueFile.WriteAt([]byte(GPIO_OFF), 0)
+	} else {
+		_, err = valueFile.WriteAt([]byte(GPIO_ON), 0)
+	}
+	return err
+}
+
+// Has the kernel raise an interrupt on the edge, and waits for it with epoll
+// on the value file.
+func (s *sysfsBackend) WatchEdge(channel uint8, edge Edge) (<-chan Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w, ok := s.watches[channel]; ok {
+		w.stop()
+		delete(s.watches, channel)
+	}
+
+	if err := writeSysfsFile(fmt.Sprintf("/sys/class/gpio/gpio%d/edge", channel), string(edge)); err != nil {
+		return nil, err
+	}
+	if edge == EdgeNone {
+		return nil, nil
+	}
+
+	// A separate handle, so reads to re-arm the interrupt don't get mixed up
+	// with Read.
+	valueFile, err := os.Open(fmt.Sprintf("/sys/class/gpio/gpio%d/value", channel))
+	if err != nil {
+		return nil, err
+	}
+	// Until the value is first read, kernfs reports it as changed, which
+	// would send a spurious event straight away
+	if _, err := readSysfsValue(valueFile); err != nil {
+		valueFile.Close()
+		return nil, err
+	}
+
+	var sequence uint64
+	w, events, err := startEdgeWatch(int(valueFile.Fd()), syscall.EPOLLPRI|syscall.EPOLLERR, func() (Event, error) {
+		// Reading from the start re-arms the interrupt
+		value, err := readSysfsValue(valueFile)
+		if err != nil {
+			return Event{}, err
+		}
+
+		sequence++
+		e := Event{
+			Kind:     EventChange,
+			Channel:  channel,
+			Edge:     edge,
+			Value:    value,
+			Time:     time.Now(),
+			Sequence: sequence,
+		}
+		if edge == EdgeBoth {
+			e.Edge = EdgeFalling
+			if value == 1 {
+				e.Edge = EdgeRising
+			}
+		}
+		return e, nil
+	})
+	if err != nil {
+		valueFile.Close()
+		return nil, err
+	}
+	w.onStop = valueFile.Close
+
+	s.watches[channel] = w
+	return events, nil
+}
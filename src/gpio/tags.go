@@ -0,0 +1,93 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Groups pins under tags such as "outdoor" or "critical", for operating on
+// them together in large installations. A pin can have any number of tags.
+type Tags struct {
+	mu       sync.RWMutex
+	channels map[string][]uint8
+	outputs  map[string][]OutputPin
+}
+
+func NewTags() *Tags {
+	return &Tags{channels: map[string][]uint8{}, outputs: map[string][]OutputPin{}}
+}
+
+// Tags a channel, for subscribing to its events by tag.
+func (t *Tags) TagChannel(channel uint8, tags ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tag := range tags {
+		t.channels[tag] = append(t.channels[tag], channel)
+	}
+}
+
+// Tags an output, for setting it by tag.
+func (t *Tags) TagOutput(pin OutputPin, tags ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tag := range tags {
+		t.outputs[tag] = append(t.outputs[tag], pin)
+	}
+}
+
+// The channels with the tag
+func (t *Tags) Channels(tag string) []uint8 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return append([]uint8(nil), t.channels[tag]...)
+}
+
+// The outputs with the tag
+func (t *Tags) Outputs(tag string) []OutputPin {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return append([]OutputPin(nil), t.outputs[tag]...)
+}
+
+// Sets every output with the tag high. All are tried even if some fail, and
+// the first error is returned.
+func (t *Tags) SetHigh(tag string) error {
+	var err error
+	for _, pin := range t.Outputs(tag) {
+		if err2 := pin.SetHigh(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// Sets every output with the tag low. All are tried even if some fail, and
+// the first error is returned.
+func (t *Tags) SetLow(tag string) error {
+	var err error
+	for _, pin := range t.Outputs(tag) {
+		if err2 := pin.SetLow(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// Subscribes to events from the channels with the tag, on bus (or DefaultBus
+// if nil). Channels tagged later are not included.
+func (t *Tags) Subscribe(bus *Bus, tag string) (*Subscription, error) {
+	channels := t.Channels(tag)
+	if len(channels) == 0 {
+		// An empty Filter would match every channel instead
+		return nil, fmt.Errorf("gpio: no channels tagged %q", tag)
+	}
+
+	if bus == nil {
+		bus = DefaultBus
+	}
+	return bus.Subscribe(Filter{Channels: channels}), nil
+}
@@ -0,0 +1,294 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrAlreadyWatching = errors.New("gpio: pin is already being watched")
+	ErrTimeout         = errors.New("gpio: timed out waiting for edge")
+)
+
+// Number of events buffered for a watcher
+const watchBuffer = 16
+
+// Reports transitions of the pin as they happen, having the kernel raise an
+// interrupt on the chosen edge rather than polling. Events are also published
+// to DefaultBus.
+//
+// The channel is closed when the pin is closed, or when watching is stopped
+// by calling Watch(EdgeNone). A pin can only have one watcher at a time.
+func (p *pin) Watch(edge Edge) (<-chan Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if edge == EdgeNone {
+		if p.stopWatch != nil {
+			return nil, p.stopWatch()
+		}
+		return nil, nil
+	}
+
+	if p.stopWatch != nil {
+		return nil, ErrAlreadyWatching
+	}
+	if p.isClosed() {
+		return nil, p.wrap("watch", ErrClosed)
+	}
+
+	// Bounces show up as both edges, whichever is wanted, so debounced pins
+	// watch both and filter once settled.
+	watched := p.physicalEdge(edge)
+	if p.debounce > 0 {
+		watched = EdgeBoth
+	}
+	raw, err := p.backend.WatchEdge(p.channel, watched)
+	if err != nil {
+		return nil, p.wrap("watch", err)
+	}
+
+	// The level to debounce changes from
+	level := -1
+	if p.debounce > 0 {
+		if val, err := p.backend.Read(p.channel); err == nil {
+			level = val
+		}
+	}
+
+	events := make(chan Event, watchBuffer)
+	quit := make(chan struct{})
+	forward := func(e Event) bool {
+		if p.activeLow {
+			e.Value ^= 1
+			e.Edge = p.physicalEdge(e.Edge)
+		}
+		DefaultBus.Publish(e)
+		select {
+		case events <- e:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+
+	go func() {
+		defer close(events)
+		if p.debounce <= 0 {
+			for e := range raw {
+				if !forward(e) {
+					return
+				}
+			}
+			return
+		}
+		p.debounceEvents(raw, p.physicalEdge(edge), level, forward, quit)
+	}()
+
+	p.stopWatch = func() error {
+		close(quit)
+		p.stopWatch = nil
+		_, err := p.backend.WatchEdge(p.channel, EdgeNone)
+		return p.wrap("watch", err)
+	}
+
+	return events, nil
+}
+
+// Forwards an event for each change in the settled level of the pin which
+// matches edge. Everything here is in physical levels.
+func (p *pin) debounceEvents(raw <-chan Event, edge Edge, level int, forward func(Event) bool, quit chan struct{}) {
+	var (
+		pending Event
+		settled <-chan time.Time
+	)
+	for {
+		select {
+		case e, ok := <-raw:
+			if !ok {
+				return
+			}
+			pending = e
+			settled = time.After(p.debounce)
+
+		case <-settled:
+			settled = nil
+			val, err := p.backend.Read(p.channel)
+			if err != nil || val == level {
+				continue
+			}
+			level = val
+
+			e := pending
+			e.Value = val
+			e.Edge = EdgeFalling
+			if val == 1 {
+				e.Edge = EdgeRising
+			}
+			if edge != EdgeBoth && edge != e.Edge {
+				continue
+			}
+			if !forward(e) {
+				return
+			}
+
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Watches the pin just for the one edge, so it fails with ErrAlreadyWatching
+// if the pin is being watched already.
+func (p *pin) WaitForEdge(edge Edge, timeout time.Duration) (Event, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	e, err := WaitForEdgeContext(ctx, p, edge)
+	if err == context.DeadlineExceeded {
+		err = ErrTimeout
+	}
+	return e, err
+}
+
+// Blocks until a transition on the edge, or until ctx is done, when it
+// returns ctx.Err(), or the pin is closed, when it returns ErrClosed. The pin
+// is watched while waiting, so it fails with ErrAlreadyWatching if the pin is
+// being watched already.
+func WaitForEdgeContext(ctx context.Context, pin InputPin, edge Edge) (Event, error) {
+	events, err := pin.Watch(edge)
+	if err != nil {
+		return Event{}, err
+	}
+	defer pin.Watch(EdgeNone)
+
+	select {
+	case e, ok := <-events:
+		if !ok {
+			return Event{}, ErrClosed
+		}
+		return e, nil
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// Swaps rising and falling for active-low pins. The mapping is its own
+// inverse, so it converts both ways.
+func (p *pin) physicalEdge(edge Edge) Edge {
+	if !p.activeLow {
+		return edge
+	}
+	switch edge {
+	case EdgeRising:
+		return EdgeFalling
+	case EdgeFalling:
+		return EdgeRising
+	}
+	return edge
+}
+
+// Reads events from a file descriptor in the background, until stopped.
+type edgeWatch struct {
+	epfd int
+	// Written to when it's time to stop
+	wake    [2]int
+	quit    chan struct{}
+	stopped chan struct{}
+	// Called once the watch has stopped
+	onStop func() error
+}
+
+// Waits for fd to be ready for events, then calls read to get an event, until
+// stopped or read fails. The returned channel is closed when the watch stops.
+func startEdgeWatch(fd int, events uint32, read func() (Event, error)) (*edgeWatch, <-chan Event, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &edgeWatch{
+		epfd:    epfd,
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if err = syscall.Pipe2(w.wake[:], syscall.O_CLOEXEC); err != nil {
+		syscall.Close(epfd)
+		return nil, nil, err
+	}
+
+	err = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &syscall.EpollEvent{
+		Events: events,
+		Fd:     int32(fd),
+	})
+	if err == nil {
+		err = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, w.wake[0], &syscall.EpollEvent{
+			Events: syscall.EPOLLIN,
+			Fd:     int32(w.wake[0]),
+		})
+	}
+	if err != nil {
+		w.close()
+		return nil, nil, err
+	}
+
+	out := make(chan Event, watchBuffer)
+	go func() {
+		defer close(w.stopped)
+		defer close(out)
+
+		ready := make([]syscall.EpollEvent, 2)
+		for {
+			n, err := syscall.EpollWait(epfd, ready, -1)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			for _, r := range ready[:n] {
+				if r.Fd == int32(w.wake[0]) {
+					return
+				}
+			}
+
+			e, err := read()
+			if err != nil {
+				return
+			}
+
+			select {
+			case out <- e:
+			case <-w.quit:
+				return
+			}
+		}
+	}()
+
+	return w, out, nil
+}
+
+func (w *edgeWatch) close() {
+	syscall.Close(w.epfd)
+	syscall.Close(w.wake[0])
+	syscall.Close(w.wake[1])
+}
+
+func (w *edgeWatch) stop() error {
+	close(w.quit)
+	syscall.Write(w.wake[1], []byte{0})
+	<-w.stopped
+	w.close()
+
+	if w.onStop != nil {
+		return w.onStop()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package gpio
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// A wind vane direction and the reading the vane gives there. Vanes switch
+// a resistor per direction into a divider, so read through an ADC each
+// direction gives a distinct voltage.
+type VanePosition struct {
+	Degrees float64
+	Reading float64
+}
+
+// Weather over one WeatherStation interval.
+type WeatherObservation struct {
+	// The end of the interval
+	Time time.Time
+	// The mean wind speed, and the highest over any GustWindow, in the units
+	// of SpeedPerHz
+	WindSpeed float64
+	Gust      float64
+	// Where the wind came from on average, in degrees. NaN until the vane
+	// has been read.
+	WindDirection float64
+	// Rain in the interval, in the units of RainPerTip
+	Rain float64
+}
+
+// Combines a cup anemometer and tipping-bucket rain gauge, which both close
+// a reed switch to pulse an input, and a wind vane read through an ADC, into
+// a stream of observations.
+type WeatherStation struct {
+	Anemometer, RainGauge uint8
+	// The edge of each pulse. EdgeFalling when empty.
+	Edge Edge
+	// Wind speed for one pulse per second, e.g. 0.667m/s for the common
+	// Argent/SparkFun meters
+	SpeedPerHz float64
+	// Rain for each tip of the bucket, e.g. 0.2794mm
+	RainPerTip float64
+	// The vane's directions. Each sample is taken as the nearest.
+	Vane []VanePosition
+	// How long each observation covers. A minute when zero.
+	Interval time.Duration
+	// The period gusts are measured over. Three seconds, as the WMO uses,
+	// when zero.
+	GustWindow time.Duration
+}
+
+type weatherInterval struct {
+	wind     []time.Time
+	tips     int
+	x, y     float64
+	bearings int
+}
+
+// Reports an observation every Interval from pulses on bus (or DefaultBus
+// if nil) and vane samples, until ctx is cancelled. vane may be nil without
+// a vane. The returned channel is closed when ctx is done.
+func (w *WeatherStation) Observations(ctx context.Context, bus *Bus, vane <-chan Sample) <-chan WeatherObservation {
+	if bus == nil {
+		bus = DefaultBus
+	}
+	edge := w.Edge
+	if edge == "" {
+		edge = EdgeFalling
+	}
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	// Dropping the oldest pulses if the consumer falls behind, rather than
+	// holding up every publisher on the bus
+	sub := bus.SubscribeWithPolicy(Filter{Kinds: []EventKind{EventChange}, Channels: []uint8{w.Anemometer, w.RainGauge}, Edge: edge}, PolicyDropOldest)
+
+	out := make(chan WeatherObservation)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		direction := math.NaN()
+		var current weatherInterval
+		for {
+			select {
+			case e := <-sub.Events():
+				if e.Channel == w.Anemometer {
+					current.wind = append(current.wind, e.Time)
+				}
+				if e.Channel == w.RainGauge {
+					current.tips++
+				}
+
+			case s, ok := <-vane:
+				if !ok {
+					vane = nil
+					continue
+				}
+				if degrees, ok := w.bearing(s.Value); ok {
+					// Averaged as vectors, so 350° and 10° average to 0°
+					// rather than 180°
+					current.x += math.Cos(degrees * math.Pi / 180)
+					current.y += math.Sin(degrees * math.Pi / 180)
+					current.bearings++
+				}
+
+			case now := <-ticker.C:
+				if current.bearings > 0 {
+					direction = math.Mod(math.Atan2(current.y, current.x)*180/math.Pi+360, 360)
+				}
+				o := WeatherObservation{
+					Time:          now,
+					WindSpeed:     float64(len(current.wind)) / interval.Seconds() * w.SpeedPerHz,
+					Gust:          w.gust(current.wind),
+					WindDirection: direction,
+					Rain:          float64(current.tips) * w.RainPerTip,
+				}
+				current = weatherInterval{}
+
+				select {
+				case out <- o:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// The direction of the vane position nearest reading
+func (w *WeatherStation) bearing(reading float64) (float64, bool) {
+	if len(w.Vane) == 0 {
+		return 0, false
+	}
+	nearest := w.Vane[0]
+	for _, p := range w.Vane[1:] {
+		if math.Abs(p.Reading-reading) < math.Abs(nearest.Reading-reading) {
+			nearest = p
+		}
+	}
+	return nearest.Degrees, true
+}
+
+// The highest wind speed over any GustWindow, from pulse times in order
+func (w *WeatherStation) gust(pulses []time.Time) float64 {
+	window := w.GustWindow
+	if window <= 0 {
+		window = 3 * time.Second
+	}
+
+	most, first := 0, 0
+	for last := range pulses {
+		for pulses[last].Sub(pulses[first]) >= window {
+			first++
+		}
+		if n := last - first + 1; n > most {
+			most = n
+		}
+	}
+	return float64(most) / window.Seconds() * w.SpeedPerHz
+}